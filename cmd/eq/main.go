@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tommjj/music_player/internal/player"
+)
+
+const usage = "usage: eq [-presets file] list | save <name> <gain>... | delete <name>"
+
+func main() {
+	presetFile := flag.String("presets", "", "EQ preset file (default in the user config directory)")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	if *presetFile == "" {
+		var err error
+		if *presetFile, err = player.DefaultEQPresetFile(); err != nil {
+			fmt.Println("Error finding the config directory:", err)
+			os.Exit(1)
+		}
+	}
+	store, err := player.LoadEQPresetStore(*presetFile)
+	if err != nil {
+		fmt.Printf("LoadEQPresetStore(%s) failed: %v\n", *presetFile, err)
+		os.Exit(1)
+	}
+
+	args := flag.Args()
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		for _, preset := range store.Presets() {
+			fmt.Printf("%-14s %v\n", preset.Name, formatGains(preset.Gains))
+		}
+	case args[0] == "save" && len(args) == 2+player.EQBands:
+		gains, err := parseGains(args[2:])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		if err := store.Save(args[1], gains); err != nil {
+			fmt.Printf("Save(%s) failed: %v\n", args[1], err)
+			os.Exit(1)
+		}
+	case args[0] == "delete" && len(args) == 2:
+		if err := store.Delete(args[1]); err != nil {
+			fmt.Printf("Delete(%s) failed: %v\n", args[1], err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Println(usage)
+		fmt.Printf("save takes the gains in dB of the %d bands at %v Hz\n", player.EQBands, player.EQFrequencies)
+		os.Exit(2)
+	}
+}
+
+// parseGains parses a gain in dB for every band.
+func parseGains(args []string) (player.EQGains, error) {
+	var gains player.EQGains
+	for i, arg := range args {
+		gain, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return gains, fmt.Errorf("gain of the %v Hz band: %w", player.EQFrequencies[i], err)
+		}
+		if gain < -player.MaxEQGain || gain > player.MaxEQGain {
+			return gains, fmt.Errorf("gain of the %v Hz band: %v dB is beyond ±%v dB", player.EQFrequencies[i], gain, player.MaxEQGain)
+		}
+		gains[i] = gain
+	}
+	return gains, nil
+}
+
+func formatGains(gains player.EQGains) string {
+	s := make([]string, len(gains))
+	for i, gain := range gains {
+		s[i] = fmt.Sprintf("%+5.1f", gain)
+	}
+	return strings.Join(s, " ")
+}
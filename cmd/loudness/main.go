@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"github.com/tommjj/music_player/internal/player"
+)
+
+// trackSaveEvery is how many scanned tracks are saved to the cache at once
+// when no albums are scanned.
+const trackSaveEvery = 20
+
+func main() {
+	cacheFile := flag.String("cache", "", "loudness cache file (default in the user cache directory)")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of files scanned at once")
+	force := flag.Bool("force", false, "scan files that are already in the cache")
+	albums := flag.Bool("albums", false, "scan every directory as an album, for album gain")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		fmt.Println("usage: loudness [-cache file] [-workers n] [-force] [-albums] <dir>")
+		os.Exit(2)
+	}
+
+	if *cacheFile == "" {
+		var err error
+		if *cacheFile, err = player.DefaultLoudnessCacheFile(); err != nil {
+			fmt.Println("Error finding the cache directory:", err)
+			os.Exit(1)
+		}
+	}
+	cache, err := player.LoadLoudnessCache(*cacheFile)
+	if err != nil {
+		fmt.Printf("LoadLoudnessCache(%s) failed: %v\n", *cacheFile, err)
+		os.Exit(1)
+	}
+
+	dirFiles := map[string][]string{}
+	err = filepath.WalkDir(flag.Arg(0), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			fmt.Printf("Error walking the path %q: %v\n", path, err)
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if _, err := player.Detect(path); err != nil {
+			return nil
+		}
+		dir := filepath.Dir(path)
+		dirFiles[dir] = append(dirFiles[dir], path)
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Error walking the directory:", err)
+		os.Exit(1)
+	}
+
+	dirs := make([]string, 0, len(dirFiles))
+	for dir := range dirFiles {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	if *albums {
+		scanAlbums(cache, dirs, dirFiles, *workers, *force)
+	} else {
+		scanTracks(cache, dirs, dirFiles, *workers, *force)
+	}
+}
+
+// scanAlbums scans the files of every directory as an album, all with one
+// pool of workers.
+func scanAlbums(cache *player.LoudnessCache, dirs []string, dirFiles map[string][]string, workers int, force bool) {
+	var names []string
+	var albums [][]string
+	for _, dir := range dirs {
+		files := dirFiles[dir]
+		if !force && scanned(cache, files, true) {
+			fmt.Printf("%s: cached\n", dir)
+			continue
+		}
+		names = append(names, dir)
+		albums = append(albums, files)
+	}
+
+	player.ScanAlbums(albums, workers, func(i int, results []player.Loudness, errs []error) {
+		fmt.Println(names[i])
+		album := -1
+		for j, file := range albums[i] {
+			if errs[j] != nil {
+				fmt.Printf("  %s: scan failed: %v\n", filepath.Base(file), errs[j])
+				continue
+			}
+			album = j
+			put(cache, file, results[j])
+			fmt.Printf("  %7.2f LUFS %7.2f dBTP  %s\n", results[j].Integrated, dB(results[j].TruePeak), filepath.Base(file))
+		}
+		if album >= 0 {
+			fmt.Printf("  %7.2f LUFS %7.2f dBTP  album\n", results[album].AlbumIntegrated, dB(results[album].AlbumTruePeak))
+		}
+
+		// Save after every album so an interrupted scan keeps its results.
+		save(cache)
+	})
+}
+
+// scanTracks scans every file on its own, all with one pool of workers.
+func scanTracks(cache *player.LoudnessCache, dirs []string, dirFiles map[string][]string, workers int, force bool) {
+	var files []string
+	cached := 0
+	for _, dir := range dirs {
+		for _, file := range dirFiles[dir] {
+			if !force && scanned(cache, []string{file}, false) {
+				cached++
+				continue
+			}
+			files = append(files, file)
+		}
+	}
+	if cached > 0 {
+		fmt.Printf("%d files cached\n", cached)
+	}
+
+	unsaved := 0
+	player.ScanTracks(files, workers, func(i int, l player.Loudness, err error) {
+		if err != nil {
+			fmt.Printf("%s: scan failed: %v\n", files[i], err)
+			return
+		}
+		if old, ok := cache.Get(files[i]); ok && old.HasAlbum {
+			// The file did not change since its album was scanned.
+			l.AlbumIntegrated, l.AlbumTruePeak, l.HasAlbum = old.AlbumIntegrated, old.AlbumTruePeak, true
+		}
+		put(cache, files[i], l)
+		fmt.Printf("%7.2f LUFS %7.2f dBTP  %s\n", l.Integrated, dB(l.TruePeak), files[i])
+
+		// Save every so often so an interrupted scan keeps its results.
+		if unsaved++; unsaved == trackSaveEvery {
+			save(cache)
+			unsaved = 0
+		}
+	})
+	if unsaved > 0 {
+		save(cache)
+	}
+}
+
+func put(cache *player.LoudnessCache, file string, l player.Loudness) {
+	if err := cache.Put(file, l); err != nil {
+		fmt.Printf("Put(%s) failed: %v\n", file, err)
+	}
+}
+
+func save(cache *player.LoudnessCache) {
+	if err := cache.Save(); err != nil {
+		fmt.Println("Error saving the cache:", err)
+		os.Exit(1)
+	}
+}
+
+// scanned reports whether every file is in the cache, with its album loudness
+// if album is set.
+func scanned(cache *player.LoudnessCache, files []string, album bool) bool {
+	for _, file := range files {
+		if l, ok := cache.Get(file); !ok || album && !l.HasAlbum {
+			return false
+		}
+	}
+	return true
+}
+
+func dB(amplitude float64) float64 {
+	return 20 * math.Log10(amplitude)
+}
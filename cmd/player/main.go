@@ -27,9 +27,9 @@ func main() {
 	}()
 
 	ctx, cal := context.WithCancel(context.Background())
-	player := player.NewPlayer()
+	p := player.NewPlayer()
 
-	player.SetOnComplete(func() {
+	p.SetOnComplete(func() {
 		fmt.Println("Playback completed")
 		cal()
 	})
@@ -39,21 +39,22 @@ func main() {
 			fmt.Printf("Error walking the path %q: %v\n", path, err)
 			return nil
 		}
-		switch filepath.Ext(path) {
-		case ".mp3", ".wav":
-		default:
-			return nil // Skip non-mp3 files
+		if d.IsDir() {
+			return nil
+		}
+		if _, err := player.Detect(path); err != nil {
+			return nil // Skip files the player can not decode
 		}
 
 		ctx, cal = context.WithCancel(context.Background())
 		fmt.Printf("Play file: %s\n", filepath.Base(path))
 
-		player.SetOnComplete(func() {
+		p.SetOnComplete(func() {
 			fmt.Printf("Playback of %s completed\n", filepath.Base(path))
 			cal()
 		})
 
-		err = player.Play(path)
+		err = p.Play(path)
 		if err != nil {
 			fmt.Printf("Player.Play(%s) failed: %v\n", path, err)
 			return nil
@@ -72,19 +73,19 @@ func main() {
 						cal() // Cancel current playback
 						return
 					case ' ':
-						if player.IsPaused() {
+						if p.IsPaused() {
 							fmt.Println("Resuming playback...")
-							player.Resume()
+							p.Resume()
 						} else {
 							fmt.Println("Pausing playback...")
-							player.Pause()
+							p.Pause()
 						}
 					case 'a', 'A': // seek
 						fmt.Println("Priv 1 second")
-						player.ToPositionByOffset(-1 * time.Second)
+						p.ToPositionByOffset(-1 * time.Second)
 					case 's', 'S': // seek
 						fmt.Println("Next 1 second")
-						player.ToPositionByOffset(time.Second)
+						p.ToPositionByOffset(time.Second)
 					}
 
 				case <-ctx.Done():
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	playmanager "github.com/tommjj/music_player/internal/play_manager"
+	"github.com/tommjj/music_player/internal/player"
 )
 
 var (
@@ -28,12 +29,16 @@ func main() {
 		if entry.IsDir() {
 			continue
 		}
+		path := filepath.Join(SongsPath, entry.Name())
+		if _, err := player.Detect(path); err != nil {
+			continue
+		}
 
 		song := &playmanager.Song{
 			Title:  entry.Name(),
 			Artist: "Unknown Artist",
-			Album:  "Unknown Album",
-			Path:   filepath.Join(SongsPath, entry.Name()),
+			Album:  playmanager.UnknownAlbum,
+			Path:   path,
 		}
 
 		songs = append(songs, song)
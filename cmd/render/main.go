@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	playmanager "github.com/tommjj/music_player/internal/play_manager"
+	"github.com/tommjj/music_player/internal/player"
+)
+
+func main() {
+	out := flag.String("o", "mix.wav", "WAV file to render into")
+	mode := flag.String("mode", playmanager.PlayModeNormal, "play order, normal or shuffle")
+	crossfade := flag.Duration("crossfade", 0, "crossfade between songs, 0 is gapless")
+	curve := flag.String("curve", string(player.CrossfadeEqualPower), "crossfade curve, linear or equal-power")
+	volume := flag.Float64("volume", 0, "volume, in the steps of the player")
+	eqPreset := flag.String("eq", "", "name of a saved EQ preset")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		fmt.Println("usage: render [-o file] [-mode mode] [-crossfade d] [-curve curve] [-volume v] [-eq preset] <dir>")
+		os.Exit(2)
+	}
+	songsPath := flag.Arg(0)
+
+	dirEntris, err := os.ReadDir(songsPath)
+	if err != nil {
+		fmt.Println("Error reading the directory:", err)
+		os.Exit(1)
+	}
+
+	songs := []*playmanager.Song{}
+	for _, entry := range dirEntris {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(songsPath, entry.Name())
+		if _, err := player.Detect(path); err != nil {
+			continue
+		}
+
+		songs = append(songs, &playmanager.Song{
+			Title:  entry.Name(),
+			Artist: "Unknown Artist",
+			Album:  playmanager.UnknownAlbum,
+			Path:   path,
+		})
+	}
+
+	// The playlist is never played live, so no audio device is opened.
+	playManager := playmanager.NewPlayManagerWithPlayer(player.NewPlayerWithOutput(player.NewNullOutput(false)))
+	playManager.AddSongs(songs...)
+	if err := playManager.SetPlayMode(*mode); err != nil {
+		fmt.Println("Error setting play mode:", err)
+		os.Exit(2)
+	}
+	playManager.SetCrossfade(*crossfade)
+	playManager.SetCrossfadeCurve(player.CrossfadeCurve(*curve))
+	playManager.Player.SetVolume(*volume)
+
+	if *eqPreset != "" {
+		if err := applyEQPreset(playManager.Player, *eqPreset); err != nil {
+			fmt.Println("Error loading the EQ preset:", err)
+			os.Exit(1)
+		}
+	}
+	if cacheFile, err := player.DefaultLoudnessCacheFile(); err == nil {
+		if cache, err := player.LoadLoudnessCache(cacheFile); err == nil {
+			playManager.Player.SetLoudnessCache(cache)
+		}
+	}
+
+	start := time.Now()
+	err = playManager.Render(*out, func(p playmanager.RenderProgress) {
+		if p.Done {
+			fmt.Printf("\r\033[Krendered %d songs into %s in %v\n", p.Count, *out, time.Since(start).Round(time.Millisecond))
+			return
+		}
+		fmt.Printf("\r\033[K[%d/%d] %s %v / %v", p.Index+1, p.Count, p.Song.Title, p.Position.Round(time.Second), p.Length.Round(time.Second))
+	})
+	if err != nil {
+		fmt.Println("\nError rendering:", err)
+		os.Exit(1)
+	}
+}
+
+// applyEQPreset sets the gains of the saved EQ preset name on p.
+func applyEQPreset(p *player.Player, name string) error {
+	filename, err := player.DefaultEQPresetFile()
+	if err != nil {
+		return err
+	}
+	store, err := player.LoadEQPresetStore(filename)
+	if err != nil {
+		return err
+	}
+	preset, err := store.Preset(name)
+	if err != nil {
+		return err
+	}
+	p.SetEQ(preset.Gains)
+	return nil
+}
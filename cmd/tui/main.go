@@ -1,21 +1,26 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	tea "github.com/charmbracelet/bubbletea"
 	playmanager "github.com/tommjj/music_player/internal/play_manager"
+	"github.com/tommjj/music_player/internal/player"
 	"github.com/tommjj/music_player/internal/tui"
 )
 
 func main() {
-	if len(os.Args) < 2 {
+	fps := flag.Int("fps", tui.DefaultFrameRate, "redraws a second of the visualizer")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
 		fmt.Print("please set path")
 		return
 	}
-	songsPath := os.Args[1]
+	songsPath := flag.Arg(0)
 
 	dirEntris, err := os.ReadDir(songsPath)
 	if err != nil {
@@ -28,12 +33,16 @@ func main() {
 		if entry.IsDir() {
 			continue
 		}
+		path := filepath.Join(songsPath, entry.Name())
+		if _, err := player.Detect(path); err != nil {
+			continue
+		}
 
 		song := &playmanager.Song{
 			Title:  entry.Name(),
 			Artist: "Unknown Artist",
-			Album:  "Unknown Album",
-			Path:   filepath.Join(songsPath, entry.Name()),
+			Album:  playmanager.UnknownAlbum,
+			Path:   path,
 		}
 
 		songs = append(songs, song)
@@ -43,10 +52,35 @@ func main() {
 	playManager.AddSongs(songs...)
 	playManager.AutoPlay = true
 
-	model := tui.NewModel(playManager)
+	eqPresets, err := loadEQPresets()
+	if err != nil {
+		panic(err)
+	}
+
+	// Songs without ReplayGain tags are levelled with the results of cmd/loudness.
+	if cacheFile, err := player.DefaultLoudnessCacheFile(); err == nil {
+		if cache, err := player.LoadLoudnessCache(cacheFile); err == nil {
+			playManager.Player.SetLoudnessCache(cache)
+		}
+	}
+
+	model := tui.NewModel(playManager, eqPresets)
+	model.SetFrameRate(*fps)
+	if dir, err := player.DefaultWaveformCacheDir(); err == nil {
+		model.SetWaveformCache(player.NewWaveformCache(dir))
+	}
 	app := tea.NewProgram(model, tea.WithAltScreen())
 	if _, err := app.Run(); err != nil {
 		println("Error starting TUI:", err.Error())
 		os.Exit(1)
 	}
 }
+
+// loadEQPresets loads the saved EQ presets from the user config directory.
+func loadEQPresets() (*player.EQPresetStore, error) {
+	filename, err := player.DefaultEQPresetFile()
+	if err != nil {
+		return nil, err
+	}
+	return player.LoadEQPresetStore(filename)
+}
@@ -3,6 +3,9 @@ package playmanager
 import (
 	"errors"
 	"math/rand"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/tommjj/music_player/internal/player"
 )
@@ -10,10 +13,18 @@ import (
 type Song struct {
 	Title  string
 	Artist string
-	Album  string
+	Album  string // empty or UnknownAlbum if not known
 	Path   string
+
+	// Gapless marks songs of albums meant to be heard without breaks,
+	// songs of the same gapless album are never crossfaded.
+	Gapless bool
 }
 
+// UnknownAlbum is the Album of songs whose album is not known. Like an empty
+// Album, it groups no songs into an album.
+const UnknownAlbum = "Unknown Album"
+
 var (
 	PlayModeNormal  = "normal"
 	PlayModeRepeat  = "repeat"
@@ -27,17 +38,44 @@ var (
 	ErrPlayerNotReady = errors.New("player is not ready")
 )
 
-// NOTE: This PlayManager is not thread-safe.
+// PlayManager plays a playlist through a Player. Its methods may be called
+// from any goroutine, the Player moves it on to the next song from its own.
 type PlayManager struct {
+	// mx guards the playlist and the play state below, the Player callbacks
+	// run on goroutines of their own.
+	mx       sync.Mutex
 	playlist []*Song
 	// currentIndex is the index of the currently playing song in the playlist.
 	currentIndex int
 	shuffleList  []int
 
 	playMode string // "normal", "repeat", "shuffle"
+	// AutoPlay moves on to the next song when one ends. Set it before
+	// playback starts.
 	AutoPlay bool
 
-	// Event handlers
+	crossfade      time.Duration // 0 means gapless
+	crossfadeCurve player.CrossfadeCurve
+
+	replayGain bool // replayGain lets the Player level songs by their ReplayGain tags
+
+	once bool // once stops after the last song of the play order instead of starting over
+
+	// gen counts the songs started with Player.Play, a callback of the Player
+	// for an older one is stale and dropped.
+	gen int
+	// playing is the song the Player plays, the one it moved on to last.
+	playing *Song
+	// queued is the song queueNext preloaded, nil if none, at queuedIndex in play order.
+	queued      *Song
+	queuedIndex int
+
+	// sleep is the running sleep timer, nil if none. It is guarded by sleepMx
+	// as the timer runs on its own goroutine. mx is taken before sleepMx.
+	sleepMx sync.Mutex
+	sleep   *sleepTimer
+
+	// Event handlers, called without any lock held.
 	OnCompleted   func(song *Song)
 	OnPlay        func(song *Song)
 	OnListChanged func(playlist []*Song)
@@ -46,67 +84,96 @@ type PlayManager struct {
 }
 
 func NewPlayManager() *PlayManager {
+	return NewPlayManagerWithPlayer(player.NewPlayer())
+}
 
-	player := player.NewPlayer()
-
+// NewPlayManagerWithPlayer creates a PlayManager that plays through p.
+func NewPlayManagerWithPlayer(p *player.Player) *PlayManager {
 	manager := &PlayManager{
-		playlist:     []*Song{},
-		currentIndex: 0,
-		playMode:     PlayModeNormal,
-		Player:       player,
+		playlist:       []*Song{},
+		currentIndex:   0,
+		playMode:       PlayModeNormal,
+		crossfadeCurve: player.CrossfadeEqualPower,
+		replayGain:     true,
+		Player:         p,
 	}
 
 	return manager
 }
 
 func (pm *PlayManager) SetSongs(songs []*Song) {
+	pm.mx.Lock()
 	pm.playlist = songs
+	pm.queueNext()
+	playlist := slices.Clone(pm.playlist)
+	pm.mx.Unlock()
 
-	if pm.OnListChanged != nil {
-		pm.OnListChanged(pm.playlist)
-	}
+	pm.listChanged(playlist)
 }
 
 func (pm *PlayManager) AddSongs(songs ...*Song) {
+	pm.mx.Lock()
 	pm.playlist = append(pm.playlist, songs...)
+	pm.queueNext()
+	playlist := slices.Clone(pm.playlist)
+	pm.mx.Unlock()
 
-	if pm.OnListChanged != nil {
-		pm.OnListChanged(pm.playlist)
-	}
+	pm.listChanged(playlist)
 }
 
 func (pm *PlayManager) RemoveSong(song *Song) {
+	pm.mx.Lock()
 	for i, s := range pm.playlist {
 		if s.Path == song.Path {
-			pm.playlist = append(pm.playlist[:i], pm.playlist[i+1:]...)
-			if pm.currentIndex >= i {
-				pm.currentIndex--
-			}
+			pm.removeSong(i)
 			break
 		}
 	}
+	pm.queueNext()
+	playlist := slices.Clone(pm.playlist)
+	pm.mx.Unlock()
 
-	if pm.OnListChanged != nil {
-		pm.OnListChanged(pm.playlist)
-	}
+	pm.listChanged(playlist)
 }
 
 func (pm *PlayManager) RemoveSongByIndex(index int) {
+	pm.mx.Lock()
 	if index < 0 || index >= len(pm.playlist) {
+		pm.mx.Unlock()
 		return
 	}
+	pm.removeSong(index)
+	pm.queueNext()
+	playlist := slices.Clone(pm.playlist)
+	pm.mx.Unlock()
+
+	pm.listChanged(playlist)
+}
+
+// removeSong removes the song at index of the playlist, pm.mx must be held.
+func (pm *PlayManager) removeSong(index int) {
 	pm.playlist = append(pm.playlist[:index], pm.playlist[index+1:]...)
 	if pm.currentIndex >= index {
 		pm.currentIndex--
 	}
+}
 
+func (pm *PlayManager) listChanged(playlist []*Song) {
 	if pm.OnListChanged != nil {
-		pm.OnListChanged(pm.playlist)
+		pm.OnListChanged(playlist)
 	}
 }
 
 func (pm *PlayManager) GetCurrentSong() (*Song, error) {
-	if pm.currentIndex < 0 || pm.currentIndex >= len(pm.playlist) {
+	pm.mx.Lock()
+	defer pm.mx.Unlock()
+
+	return pm.songAt(pm.currentIndex)
+}
+
+// songAt returns the song at index in play order, pm.mx must be held.
+func (pm *PlayManager) songAt(index int) (*Song, error) {
+	if index < 0 || index >= len(pm.playlist) {
 		return nil, ErrInvalidIndex
 	}
 	switch pm.playMode {
@@ -114,91 +181,320 @@ func (pm *PlayManager) GetCurrentSong() (*Song, error) {
 		if len(pm.shuffleList) != len(pm.playlist) {
 			pm.initShuffleList(pm.currentIndex)
 		}
-		return pm.playlist[pm.shuffleList[pm.currentIndex]], nil
+		return pm.playlist[pm.shuffleList[index]], nil
 	default:
-		return pm.playlist[pm.currentIndex], nil
+		return pm.playlist[index], nil
 	}
 }
 
-func (pm *PlayManager) initOnCompleteEventHandler(song *Song) {
-	pm.Player.SetOnComplete(func() {
-		if pm.OnCompleted != nil {
-			pm.OnCompleted(song)
+// nextIndex returns the index PlayNext moves to, pm.mx must be held.
+func (pm *PlayManager) nextIndex() int {
+	switch pm.playMode {
+	case PlayModeRepeat:
+		return pm.currentIndex // Stay on the current song
+	default:
+		if pm.currentIndex+1 >= len(pm.playlist) {
+			if pm.once {
+				return -1 // No song follows
+			}
+			return 0 // Loop back to the start
 		}
+		return pm.currentIndex + 1
+	}
+}
 
-		// Auto Play mode
-		if pm.AutoPlay {
-			pm.PlayNext()
-		}
-	})
+// initOnCompleteEventHandler points the Player callbacks at gen, the count
+// of the song started last. pm.mx must be held.
+func (pm *PlayManager) initOnCompleteEventHandler(gen int) {
+	pm.Player.SetOnComplete(func() { pm.songEnded(gen) })
+	pm.Player.SetOnAdvance(func(filename string) { pm.advanced(gen, filename) })
 }
 
-func (pm *PlayManager) play(song *Song) error {
-	if err := pm.Player.Play(song.Path); err != nil {
-		return err
+// songEnded is called when the song playing since gen ended with nothing queued.
+func (pm *PlayManager) songEnded(gen int) {
+	pm.mx.Lock()
+	if gen != pm.gen {
+		pm.mx.Unlock()
+		return // Another song was started meanwhile
+	}
+	song := pm.playing
+	var next *Song
+	if !pm.sleepTrackEnded() && pm.AutoPlay {
+		// The sleep timer did not stop playback.
+		pm.currentIndex = pm.nextIndex()
+		next, _ = pm.playCurrent()
+	}
+	pm.mx.Unlock()
+
+	pm.completed(song)
+	if next != nil {
+		pm.played(next)
 	}
-	// Notify the onPlay callback if set
+}
+
+// advanced is called when the Player, playing since gen, moved on to
+// filename, the song preloaded by queueNext, without a gap.
+func (pm *PlayManager) advanced(gen int, filename string) {
+	pm.mx.Lock()
+	song, next := pm.playing, pm.queued
+	if gen != pm.gen || next == nil || next.Path != filename {
+		pm.mx.Unlock()
+		return // Another song was started or queued meanwhile
+	}
+	pm.sleepTrackEnded()
+	pm.currentIndex = pm.queuedIndex
+	pm.moved(next)
+	pm.mx.Unlock()
+
+	pm.completed(song)
+	pm.played(next)
+}
+
+func (pm *PlayManager) completed(song *Song) {
+	if pm.OnCompleted != nil {
+		pm.OnCompleted(song)
+	}
+}
+
+func (pm *PlayManager) played(song *Song) {
 	if pm.OnPlay != nil {
 		pm.OnPlay(song)
 	}
+}
 
-	// Add on
-	pm.initOnCompleteEventHandler(song)
+// queueNext preloads the song that follows the current one in play order,
+// so the Player can start it without a gap. It only does so in AutoPlay mode,
+// and not when the sleep timer stops playback after the current song.
+// pm.mx must be held.
+func (pm *PlayManager) queueNext() {
+	if !pm.AutoPlay || pm.Player == nil || len(pm.playlist) == 0 {
+		return
+	}
+	pm.queued = nil
+	if pm.sleepsAfterCurrent() {
+		pm.Player.ClearQueue()
+		return
+	}
 
-	return nil
-}
+	index := pm.nextIndex()
+	next, err := pm.songAt(index)
+	if err != nil {
+		pm.Player.ClearQueue()
+		return
+	}
 
-func (pm *PlayManager) PlayCurrent() error {
-	if pm.Player == nil {
-		return ErrPlayerNotReady
+	current, err := pm.songAt(pm.currentIndex)
+	if err != nil {
+		pm.Player.ClearQueue()
+		return
 	}
 
-	currentSong, err := pm.GetCurrentSong()
+	switch {
+	case pm.shouldCrossfade(current, next):
+		err = pm.Player.QueueCrossfade(next.Path, pm.crossfade, pm.crossfadeCurve)
+	case sameGaplessAlbum(current, next):
+		// The album flows from one song into the next, its silence stays.
+		err = pm.Player.QueueJoined(next.Path)
+	default:
+		err = pm.Player.Queue(next.Path)
+	}
 	if err != nil {
-		return err
+		pm.Player.ClearQueue()
+		return
 	}
+	pm.queued, pm.queuedIndex = next, index
+}
 
-	if err := pm.play(currentSong); err != nil {
-		return err
+// shouldCrossfade reports whether the move from current to next is crossfaded.
+// Repeating a song and moving within a gapless album stay gapless.
+func (pm *PlayManager) shouldCrossfade(current, next *Song) bool {
+	if pm.crossfade <= 0 || pm.playMode == PlayModeRepeat {
+		return false
 	}
+	return !sameGaplessAlbum(current, next)
+}
 
-	return nil
+// sameGaplessAlbum reports whether current and next are songs of the same gapless album.
+func sameGaplessAlbum(current, next *Song) bool {
+	return current.Gapless && next.Gapless && sameAlbum(current, next)
 }
 
-func (pm *PlayManager) PlayNext() error {
-	if len(pm.playlist) == 0 {
-		return ErrPlaylistEmpty
+// sameAlbum reports whether a and b are songs of the same known album.
+func sameAlbum(a, b *Song) bool {
+	return a.Album != "" && a.Album != UnknownAlbum && a.Album == b.Album
+}
+
+// Crossfade returns the length of the crossfade between songs, 0 when disabled.
+func (pm *PlayManager) Crossfade() time.Duration {
+	pm.mx.Lock()
+	defer pm.mx.Unlock()
+
+	return pm.crossfade
+}
+
+// SetCrossfade sets the length of the crossfade between songs, 0 disables it.
+// It also applies to the song already preloaded.
+func (pm *PlayManager) SetCrossfade(d time.Duration) {
+	pm.mx.Lock()
+	defer pm.mx.Unlock()
+
+	if d < 0 {
+		d = 0
 	}
+	pm.crossfade = d
+	pm.queueNext()
+}
 
-	switch pm.playMode {
-	case PlayModeNormal, "", PlayModeShuffle:
-		pm.currentIndex++
-		if pm.currentIndex >= len(pm.playlist) {
-			pm.currentIndex = 0 // Loop back to the start
+func (pm *PlayManager) CrossfadeCurve() player.CrossfadeCurve {
+	pm.mx.Lock()
+	defer pm.mx.Unlock()
+
+	return pm.crossfadeCurve
+}
+
+func (pm *PlayManager) SetCrossfadeCurve(curve player.CrossfadeCurve) {
+	pm.mx.Lock()
+	defer pm.mx.Unlock()
+
+	pm.crossfadeCurve = curve
+	pm.queueNext()
+}
+
+// ReplayGain reports whether songs are leveled by their ReplayGain tags.
+func (pm *PlayManager) ReplayGain() bool {
+	pm.mx.Lock()
+	defer pm.mx.Unlock()
+
+	return pm.replayGain
+}
+
+// SetReplayGain turns leveling songs by their ReplayGain tags on or off.
+// The PlayManager picks album or track gain itself, see replayGainMode.
+func (pm *PlayManager) SetReplayGain(enabled bool) {
+	pm.mx.Lock()
+	defer pm.mx.Unlock()
+
+	pm.replayGain = enabled
+
+	if song, err := pm.songAt(pm.currentIndex); err == nil {
+		pm.applyReplayGain(song)
+	}
+}
+
+// replayGainMode picks album gain while song is played along with its album
+// in order, so the album keeps its dynamics, and track gain otherwise.
+// pm.mx must be held.
+func (pm *PlayManager) replayGainMode(song *Song) player.ReplayGainMode {
+	if !pm.replayGain {
+		return player.ReplayGainOff
+	}
+	if pm.playMode == PlayModeShuffle {
+		return player.ReplayGainTrack
+	}
+
+	for _, index := range []int{pm.currentIndex - 1, pm.currentIndex + 1} {
+		if neighbor, err := pm.songAt(index); err == nil && neighbor != song && sameAlbum(neighbor, song) {
+			return player.ReplayGainAlbum
 		}
-	case PlayModeRepeat:
-		// Do nothing, stay on the current song
 	}
+	return player.ReplayGainTrack
+}
+
+func (pm *PlayManager) applyReplayGain(song *Song) {
+	if pm.Player != nil {
+		pm.Player.SetReplayGainMode(pm.replayGainMode(song))
+	}
+}
+
+// started is called once Player.Play started song, pm.mx must be held. The
+// OnPlay callback is left to the caller, to call once pm.mx is released.
+func (pm *PlayManager) started(song *Song) {
+	pm.gen++
+	pm.initOnCompleteEventHandler(pm.gen)
 
-	return pm.PlayCurrent()
+	pm.moved(song)
 }
 
-func (pm *PlayManager) PlayPrevious() error {
-	if len(pm.playlist) == 0 {
-		return ErrPlaylistEmpty
+// moved is called once the Player plays song, started or moved on to.
+// pm.mx must be held.
+func (pm *PlayManager) moved(song *Song) {
+	pm.playing = song
+	pm.applyReplayGain(song)
+
+	pm.queueNext()
+}
+
+// playCurrent plays the current song, pm.mx must be held.
+func (pm *PlayManager) playCurrent() (*Song, error) {
+	if pm.Player == nil {
+		return nil, ErrPlayerNotReady
 	}
 
-	switch pm.playMode {
-	case PlayModeNormal, "", PlayModeShuffle:
-		pm.currentIndex--
-		if pm.currentIndex < 0 {
-			pm.currentIndex = len(pm.playlist) - 1 // Loop back to the end
-		}
-	case PlayModeRepeat:
-		// Do nothing, stay on the current song
+	song, err := pm.songAt(pm.currentIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set before playing, so the song starts at the right level.
+	pm.applyReplayGain(song)
+
+	if err := pm.Player.Play(song.Path); err != nil {
+		return nil, err
+	}
+
+	pm.started(song)
+	return song, nil
+}
+
+// playLocked runs move with pm.mx held, then plays the current song and calls
+// the OnPlay callback once pm.mx is released.
+func (pm *PlayManager) playLocked(move func() error) error {
+	pm.mx.Lock()
+	var song *Song
+	err := move()
+	if err == nil {
+		song, err = pm.playCurrent()
 	}
+	pm.mx.Unlock()
 
-	return pm.PlayCurrent()
+	if err != nil {
+		return err
+	}
+	pm.played(song)
+	return nil
+}
+
+func (pm *PlayManager) PlayCurrent() error {
+	return pm.playLocked(func() error { return nil })
+}
+
+func (pm *PlayManager) PlayNext() error {
+	return pm.playLocked(func() error {
+		if len(pm.playlist) == 0 {
+			return ErrPlaylistEmpty
+		}
+		pm.currentIndex = pm.nextIndex()
+		return nil
+	})
+}
+
+func (pm *PlayManager) PlayPrevious() error {
+	return pm.playLocked(func() error {
+		if len(pm.playlist) == 0 {
+			return ErrPlaylistEmpty
+		}
+
+		switch pm.playMode {
+		case PlayModeNormal, "", PlayModeShuffle:
+			pm.currentIndex--
+			if pm.currentIndex < 0 {
+				pm.currentIndex = len(pm.playlist) - 1 // Loop back to the end
+			}
+		case PlayModeRepeat:
+			// Do nothing, stay on the current song
+		}
+		return nil
+	})
 }
 
 func (pm *PlayManager) PlaySong(song *Song) error {
@@ -206,19 +502,28 @@ func (pm *PlayManager) PlaySong(song *Song) error {
 		return ErrPlayerNotReady
 	}
 
-	songPlaylistIndex := pm.findSongIndex(song)
-
-	if songPlaylistIndex > 0 {
+	pm.mx.Lock()
+	if songPlaylistIndex := pm.findSongIndex(song); songPlaylistIndex > 0 {
 		pm.currentIndex = songPlaylistIndex
 	}
 
-	if err := pm.play(song); err != nil {
-		return err
+	// Set before playing, so the song starts at the right level.
+	pm.applyReplayGain(song)
+	err := pm.Player.Play(song.Path)
+	if err == nil {
+		pm.started(song)
 	}
+	pm.mx.Unlock()
 
+	if err != nil {
+		return err
+	}
+	pm.played(song)
 	return nil
 }
 
+// findSongIndex returns the index of song in play order, -1 if it is not in
+// the playlist. pm.mx must be held.
 func (pm *PlayManager) findSongIndex(song *Song) int {
 	originalIndex := -1
 
@@ -251,22 +556,44 @@ func (pm *PlayManager) findSongIndex(song *Song) int {
 }
 
 func (pm *PlayManager) PlaySongByIndex(index int) error {
-	if index < 0 || index >= len(pm.playlist) {
-		return ErrInvalidIndex
-	}
-	pm.currentIndex = index
-	return pm.PlayCurrent()
+	return pm.playLocked(func() error {
+		if index < 0 || index >= len(pm.playlist) {
+			return ErrInvalidIndex
+		}
+		pm.currentIndex = index
+		return nil
+	})
 }
 
 func (pm *PlayManager) PlayMode() string {
+	pm.mx.Lock()
+	defer pm.mx.Unlock()
+
 	return pm.playMode
 }
 
 func (pm *PlayManager) SetPlayMode(mode string) error {
+	pm.mx.Lock()
+	defer pm.mx.Unlock()
+
 	if mode == pm.playMode {
 		return nil // No change needed
 	}
 
+	if err := pm.setPlayMode(mode); err != nil {
+		return err
+	}
+
+	// The preloaded song and the ReplayGain mode depend on the play order.
+	if song, err := pm.songAt(pm.currentIndex); err == nil {
+		pm.applyReplayGain(song)
+	}
+	pm.queueNext()
+	return nil
+}
+
+// setPlayMode switches to mode keeping the current song, pm.mx must be held.
+func (pm *PlayManager) setPlayMode(mode string) error {
 	switch mode {
 	case PlayModeNormal, "":
 		if pm.playMode == PlayModeShuffle {
@@ -331,14 +658,22 @@ func shuffle(slice []int, firstItemIndex int) {
 }
 
 func (pm *PlayManager) ResetShuffleList() {
+	pm.mx.Lock()
+	defer pm.mx.Unlock()
+
 	if pm.playMode != PlayModeShuffle {
 		return
 	}
 	pm.initShuffleList(pm.currentIndex)
 	pm.currentIndex = 0
+
+	pm.queueNext()
 }
 
 func (pm *PlayManager) PlayList() []*Song {
+	pm.mx.Lock()
+	defer pm.mx.Unlock()
+
 	if pm.playMode == PlayModeShuffle {
 		if len(pm.shuffleList) != len(pm.playlist) {
 			pm.initShuffleList(pm.currentIndex)
@@ -350,5 +685,5 @@ func (pm *PlayManager) PlayList() []*Song {
 		return shuffledPlaylist
 	}
 
-	return pm.playlist
+	return slices.Clone(pm.playlist)
 }
@@ -0,0 +1,148 @@
+package playmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tommjj/music_player/internal/player"
+)
+
+func TestPlayManager_Advance(t *testing.T) {
+	pm := newTestPlayManager(t, 2, 300*time.Millisecond)
+	songs := pm.PlayList()
+
+	if err := pm.PlayCurrent(); err != nil {
+		t.Fatalf("PlayCurrent failed: %v", err)
+	}
+
+	// The Player moves on to the preloaded song from its own goroutine while
+	// this one reads the current song.
+	eventually(t, "the second song plays", func() bool {
+		song, err := pm.GetCurrentSong()
+		return err == nil && song == songs[1]
+	})
+}
+
+func TestPlayManager_StaleCallbacks(t *testing.T) {
+	pm := newTestPlayManager(t, 3, 5*time.Second)
+	songs := pm.PlayList()
+
+	if err := pm.PlayCurrent(); err != nil {
+		t.Fatalf("PlayCurrent failed: %v", err)
+	}
+	pm.mx.Lock()
+	first := pm.gen
+	pm.mx.Unlock()
+	if err := pm.PlayNext(); err != nil {
+		t.Fatalf("PlayNext failed: %v", err)
+	}
+	pm.mx.Lock()
+	second := pm.gen
+	pm.mx.Unlock()
+
+	// Callbacks of the first song arrive after PlayNext, they must not move on again.
+	pm.advanced(first, songs[1].Path)
+	pm.songEnded(first)
+	// The Player moved on to a song that is no longer the queued one.
+	pm.advanced(second, songs[0].Path)
+	if song, _ := pm.GetCurrentSong(); song != songs[1] {
+		t.Fatalf("current song after stale callbacks = %v, want %v", song.Title, songs[1].Title)
+	}
+
+	pm.advanced(second, songs[2].Path)
+	if song, _ := pm.GetCurrentSong(); song != songs[2] {
+		t.Errorf("current song after the advance = %v, want %v", song.Title, songs[2].Title)
+	}
+}
+
+func TestPlayManager_ReplayGainMode(t *testing.T) {
+	a1 := &Song{Title: "a1", Path: "a1", Album: "A"}
+	a2 := &Song{Title: "a2", Path: "a2", Album: "A"}
+	b := &Song{Title: "b", Path: "b", Album: "B"}
+	u1 := &Song{Title: "u1", Path: "u1", Album: UnknownAlbum}
+	u2 := &Song{Title: "u2", Path: "u2", Album: UnknownAlbum}
+	e1 := &Song{Title: "e1", Path: "e1"}
+	e2 := &Song{Title: "e2", Path: "e2"}
+
+	tests := []struct {
+		name       string
+		songs      []*Song
+		index      int
+		mode       string
+		replayGain bool
+		want       player.ReplayGainMode
+	}{
+		{"album in order", []*Song{a1, a2, b}, 0, PlayModeNormal, true, player.ReplayGainAlbum},
+		{"album neighbor before", []*Song{b, a1, a2}, 2, PlayModeNormal, true, player.ReplayGainAlbum},
+		{"no album neighbor", []*Song{a1, b, a2}, 1, PlayModeNormal, true, player.ReplayGainTrack},
+		{"unknown albums", []*Song{u1, u2}, 0, PlayModeNormal, true, player.ReplayGainTrack},
+		{"empty albums", []*Song{e1, e2}, 0, PlayModeNormal, true, player.ReplayGainTrack},
+		{"repeat", []*Song{a1, a2}, 0, PlayModeRepeat, true, player.ReplayGainAlbum},
+		{"off", []*Song{a1, a2}, 0, PlayModeNormal, false, player.ReplayGainOff},
+	}
+	for _, tt := range tests {
+		pm := NewPlayManagerWithPlayer(nil)
+		pm.SetSongs(tt.songs)
+		pm.SetReplayGain(tt.replayGain)
+		if err := pm.SetPlayMode(tt.mode); err != nil {
+			t.Fatalf("%s: SetPlayMode(%s) failed: %v", tt.name, tt.mode, err)
+		}
+
+		pm.mx.Lock()
+		pm.currentIndex = tt.index
+		got := pm.replayGainMode(tt.songs[tt.index])
+		pm.mx.Unlock()
+		if got != tt.want {
+			t.Errorf("%s: replayGainMode = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Shuffled songs never play along with their album.
+	pm := NewPlayManagerWithPlayer(nil)
+	pm.SetSongs([]*Song{a1, a2})
+	pm.SetPlayMode(PlayModeShuffle)
+	pm.mx.Lock()
+	defer pm.mx.Unlock()
+	if got := pm.replayGainMode(a1); got != player.ReplayGainTrack {
+		t.Errorf("shuffled: replayGainMode = %v, want %v", got, player.ReplayGainTrack)
+	}
+}
+
+func TestPlayManager_ShouldCrossfade(t *testing.T) {
+	live1 := &Song{Title: "live1", Path: "live1", Album: "Live", Gapless: true}
+	live2 := &Song{Title: "live2", Path: "live2", Album: "Live", Gapless: true}
+	other := &Song{Title: "other", Path: "other", Album: "Other", Gapless: true}
+	studio := &Song{Title: "studio", Path: "studio", Album: "Live"}
+	unknown1 := &Song{Title: "unknown1", Path: "unknown1", Album: UnknownAlbum, Gapless: true}
+	unknown2 := &Song{Title: "unknown2", Path: "unknown2", Album: UnknownAlbum, Gapless: true}
+
+	tests := []struct {
+		name          string
+		crossfade     time.Duration
+		mode          string
+		current, next *Song
+		want          bool
+	}{
+		{"gapless album", time.Second, PlayModeNormal, live1, live2, false},
+		{"other album", time.Second, PlayModeNormal, live1, other, true},
+		{"not gapless", time.Second, PlayModeNormal, studio, live1, true},
+		{"unknown album", time.Second, PlayModeNormal, unknown1, unknown2, true},
+		{"repeat", time.Second, PlayModeRepeat, other, other, false},
+		{"shuffle", time.Second, PlayModeShuffle, live1, live2, false},
+		{"disabled", 0, PlayModeNormal, live1, other, false},
+	}
+	for _, tt := range tests {
+		pm := NewPlayManagerWithPlayer(nil)
+		pm.SetCrossfade(tt.crossfade)
+		if err := pm.SetPlayMode(tt.mode); err != nil {
+			t.Fatalf("%s: SetPlayMode(%s) failed: %v", tt.name, tt.mode, err)
+		}
+
+		pm.mx.Lock()
+		got := pm.shouldCrossfade(tt.current, tt.next)
+		pm.mx.Unlock()
+		if got != tt.want {
+			t.Errorf("%s: shouldCrossfade = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
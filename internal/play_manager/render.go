@@ -0,0 +1,165 @@
+package playmanager
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tommjj/music_player/internal/player"
+)
+
+// renderProgressInterval is how often Render reports its progress.
+const renderProgressInterval = 200 * time.Millisecond
+
+// RenderProgress reports how far Render got.
+type RenderProgress struct {
+	Song     *Song
+	Index    int // the index of Song in the render, from 0
+	Count    int // the number of songs in the render
+	Position time.Duration
+	Length   time.Duration
+	Done     bool // set on the last report, once the file is complete
+}
+
+// Render plays the playlist once through into a 16-bit WAV file at filename,
+// as fast as the songs decode and without an audio device. It follows the
+// play order from its first song and plays through a Player of its own with
+// the volume, speed, EQ, channel, crossfeed, effects, limiter, silence trim
+// and loudness settings of pm.Player and the crossfade and ReplayGain settings
+// of pm, so the file sounds like the playlist does. In repeat mode every song
+// is rendered once.
+//
+// progress, if not nil, is called every so often and once more when done.
+// Render stops at the first song that fails to play, the file then holds
+// everything before it. It also stops when writing the file fails.
+func (pm *PlayManager) Render(filename string, progress func(RenderProgress)) error {
+	pm.mx.Lock()
+	if len(pm.playlist) == 0 {
+		pm.mx.Unlock()
+		return ErrPlaylistEmpty
+	}
+
+	songs := make([]*Song, len(pm.playlist))
+	for i := range songs {
+		songs[i], _ = pm.songAt(i)
+	}
+	r := &PlayManager{
+		playlist:       slices.Clone(pm.playlist),
+		shuffleList:    slices.Clone(pm.shuffleList),
+		playMode:       pm.playMode,
+		AutoPlay:       true,
+		crossfade:      pm.crossfade,
+		crossfadeCurve: pm.crossfadeCurve,
+		replayGain:     pm.replayGain,
+		once:           true,
+	}
+	pm.mx.Unlock()
+
+	output, err := player.NewWAVOutput(filename, false)
+	if err != nil {
+		return err
+	}
+	p := player.NewPlayerWithOutput(output)
+	if pm.Player != nil {
+		copyPlayerSettings(p, pm.Player)
+	}
+
+	r.Player = p
+	if r.playMode == PlayModeRepeat {
+		r.playMode = PlayModeNormal
+	}
+
+	// The callbacks run on goroutines of the Player.
+	var mx sync.Mutex
+	index := -1
+	r.OnPlay = func(*Song) {
+		mx.Lock()
+		index++
+		mx.Unlock()
+	}
+	completed := make(chan struct{}, len(songs))
+	r.OnCompleted = func(*Song) { completed <- struct{}{} }
+
+	events, cancel := p.Subscribe()
+	defer cancel()
+
+	report := func() {
+		if progress == nil {
+			return
+		}
+		mx.Lock()
+		i := max(index, 0)
+		mx.Unlock()
+		info := p.Info()
+		progress(RenderProgress{Song: songs[i], Index: i, Count: len(songs), Position: info.Current, Length: info.Length})
+	}
+
+	err = r.PlayCurrent()
+	if err == nil {
+		err = waitForRender(len(songs), completed, events, output.Err, func() bool { return stopped(p.Info()) }, report)
+	}
+
+	// Nothing may start another song while the Player winds down.
+	r.mx.Lock()
+	r.AutoPlay = false
+	r.mx.Unlock()
+	p.SetOnComplete(nil)
+	p.SetOnAdvance(nil)
+	p.Close()
+	if cerr := output.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	if err == nil && progress != nil {
+		last := songs[len(songs)-1]
+		progress(RenderProgress{Song: last, Index: len(songs) - 1, Count: len(songs), Done: true})
+	}
+	return err
+}
+
+// waitForRender waits until count songs completed, calling report in between.
+// It returns the error of a song that failed to play once playback stopped,
+// or of the output once it failed to write. A song that fails to be queued
+// only stops playback once the song before it ended.
+func waitForRender(count int, completed <-chan struct{}, events <-chan player.Event, outputErr func() error, stopped func() bool, report func()) error {
+	ticker := time.NewTicker(renderProgressInterval)
+	defer ticker.Stop()
+
+	for done := 0; done < count; {
+		select {
+		case <-completed:
+			done++
+		case e := <-events:
+			if e.Type == player.EventDecodeError && stopped() {
+				return e.Err
+			}
+		case <-ticker.C:
+			if err := outputErr(); err != nil {
+				return err
+			}
+			report()
+		}
+	}
+	return nil
+}
+
+// stopped reports whether playback stopped, with nothing loaded or at a decode error.
+func stopped(info *player.Info) bool {
+	return info.Name == "" || info.Err != nil
+}
+
+// copyPlayerSettings gives p the settings of from that shape the sound.
+func copyPlayerSettings(p, from *player.Player) {
+	info := from.Info()
+	p.SetVolume(info.Volume)
+	p.SetSpeed(info.Speed)
+	p.SetPreservePitch(info.PreservePitch)
+	p.SetEQ(from.EQ())
+	p.SetLimiter(from.Limiter())
+	p.SetFades(from.Fades())
+	p.SetSilenceTrim(from.SilenceTrim())
+	p.SetChannelMode(info.ChannelMode)
+	p.SetBalance(info.Balance)
+	p.SetCrossfeed(info.Crossfeed)
+	p.SetEffects(from.Effects())
+	p.SetLoudnessCache(from.LoudnessCache())
+}
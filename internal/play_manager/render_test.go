@@ -0,0 +1,144 @@
+package playmanager
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tommjj/music_player/internal/player"
+)
+
+func TestPlayManager_Render(t *testing.T) {
+	dir := t.TempDir()
+	var songs []*Song
+	for _, name := range []string{"first.wav", "second.wav"} {
+		fileName := filepath.Join(dir, name)
+		writeTestWAV(t, fileName, time.Second)
+		songs = append(songs, &Song{Title: name, Path: fileName})
+	}
+
+	output := player.NewNullOutput(true)
+	defer output.Close()
+	pm := NewPlayManagerWithPlayer(player.NewPlayerWithOutput(output))
+	pm.SetSongs(songs)
+
+	outName := filepath.Join(dir, "out.wav")
+	var last RenderProgress
+	if err := pm.Render(outName, func(p RenderProgress) { last = p }); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !last.Done || last.Song != songs[1] || last.Count != 2 {
+		t.Errorf("last progress = %+v, want done with the second song", last)
+	}
+
+	data, err := os.ReadFile(outName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) < 44 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" || string(data[36:40]) != "data" {
+		t.Fatalf("rendered file starts with %q, want a WAV header", data[:min(len(data), 44)])
+	}
+	channels := binary.LittleEndian.Uint16(data[22:])
+	rate := binary.LittleEndian.Uint32(data[24:])
+	bits := binary.LittleEndian.Uint16(data[34:])
+	if channels != 2 || rate != uint32(player.DefaultSampleRate) || bits != 16 {
+		t.Errorf("format = %d channels at %d Hz with %d bits, want 2 at %d with 16", channels, rate, bits, player.DefaultSampleRate)
+	}
+	size := binary.LittleEndian.Uint32(data[40:])
+	if int(size) != len(data)-44 || binary.LittleEndian.Uint32(data[4:]) != size+36 {
+		t.Errorf("header sizes = %d and %d for %d bytes of data", binary.LittleEndian.Uint32(data[4:]), size, len(data)-44)
+	}
+
+	// Both songs back to back, without padding.
+	if want := player.DefaultSampleRate.N(2 * time.Second); int(size)/4 != want {
+		t.Errorf("rendered %d samples, want %d", size/4, want)
+	}
+}
+
+func TestPlayManager_RenderCrossfade(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.wav")
+	second := filepath.Join(dir, "second.wav")
+	writeTestWAV(t, first, time.Second)
+	writeTestWAV(t, second, time.Second)
+
+	tests := []struct {
+		name          string
+		first, second string // the albums of the songs
+		want          time.Duration
+	}{
+		// Songs of a gapless album are joined, the others overlap by the crossfade.
+		{"gapless album", "Live", "Live", 2 * time.Second},
+		{"other albums", "Live", "Other", 2*time.Second - time.Second/2},
+		{"unknown albums", UnknownAlbum, UnknownAlbum, 2*time.Second - time.Second/2},
+	}
+	for _, tt := range tests {
+		pm := NewPlayManagerWithPlayer(nil)
+		pm.SetCrossfade(time.Second / 2)
+		pm.SetSongs([]*Song{
+			{Title: "first", Path: first, Album: tt.first, Gapless: true},
+			{Title: "second", Path: second, Album: tt.second, Gapless: true},
+		})
+
+		outName := filepath.Join(dir, "out.wav")
+		if err := pm.Render(outName, nil); err != nil {
+			t.Fatalf("%s: Render failed: %v", tt.name, err)
+		}
+		data, err := os.ReadFile(outName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := player.DefaultSampleRate.D((len(data) - 44) / 4); got < tt.want-10*time.Millisecond || got > tt.want+10*time.Millisecond {
+			t.Errorf("%s: rendered %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlayManager_RenderError(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.wav")
+	broken := filepath.Join(dir, "broken.wav")
+	writeTestWAV(t, good, time.Second/2)
+	if err := os.WriteFile(broken, []byte("not a wav file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pm := NewPlayManagerWithPlayer(nil)
+	pm.SetSongs([]*Song{{Title: "good", Path: good}, {Title: "broken", Path: broken}})
+
+	// Render stops at the song that fails, the file holds the one before it.
+	outName := filepath.Join(dir, "out.wav")
+	if err := pm.Render(outName, nil); err == nil {
+		t.Fatal("Render of a broken song succeeded, want an error")
+	}
+	data, err := os.ReadFile(outName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := player.DefaultSampleRate.N(time.Second / 2); len(data) < 44 || (len(data)-44)/4 < want {
+		t.Errorf("rendered %d bytes, want the %d samples of the first song", len(data), want)
+	}
+}
+
+func TestWaitForRender_OutputError(t *testing.T) {
+	errWrite := errors.New("no space left on device")
+	completed := make(chan struct{})
+	events := make(chan player.Event)
+
+	// Once the output failed no song completes any more, the wait must end anyway.
+	done := make(chan error, 1)
+	go func() {
+		done <- waitForRender(2, completed, events, func() error { return errWrite }, func() bool { return false }, func() {})
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errWrite) {
+			t.Errorf("waitForRender = %v, want %v", err, errWrite)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitForRender did not return after the output failed")
+	}
+}
@@ -0,0 +1,197 @@
+package playmanager
+
+import "time"
+
+const (
+	sleepTick = 50 * time.Millisecond // how often a running sleep timer checks its deadline
+	// sleepFadeDepth is how far the fade turns the volume down, the Player
+	// volume is in powers of 2, so 10 is about -60 dB.
+	sleepFadeDepth = 10.0
+)
+
+// SleepStatus describes the sleep timer.
+type SleepStatus struct {
+	Active bool
+	// Remaining is the time until playback stops. With a track count it is
+	// only known once the last track plays, see Known.
+	Remaining time.Duration
+	Known     bool
+	// Tracks is the number of tracks left to play, the current one included,
+	// 0 for a timer by time.
+	Tracks int
+	Fading bool
+}
+
+// sleepTimer pauses playback at its deadline, or when its last track ends.
+type sleepTimer struct {
+	deadline time.Time // the end of a timer by time
+	tracks   int       // tracks left including the current one, 0 for a timer by time
+	fade     time.Duration
+
+	fading bool
+	volume float64 // the volume before the fade, restored when the timer ends
+	done   chan struct{}
+}
+
+// SleepAfter pauses playback after d. Over the last fade of it the volume is
+// turned down, it is restored once playback paused. A running timer is replaced.
+func (pm *PlayManager) SleepAfter(d, fade time.Duration) {
+	pm.startSleep(&sleepTimer{deadline: time.Now().Add(d), fade: fade})
+}
+
+// SleepAfterTracks pauses playback at the end of the n-th track, counting the
+// current one, so 1 stops at the end of the current track. The volume is turned
+// down over the last fade of that track. A running timer is replaced.
+func (pm *PlayManager) SleepAfterTracks(n int, fade time.Duration) error {
+	if n < 1 {
+		return ErrInvalidIndex
+	}
+	pm.startSleep(&sleepTimer{tracks: n, fade: fade})
+	return nil
+}
+
+// CancelSleep stops the sleep timer, a volume that was fading is restored.
+func (pm *PlayManager) CancelSleep() {
+	pm.sleepMx.Lock()
+	pm.stopSleep()
+	pm.sleepMx.Unlock()
+
+	pm.mx.Lock()
+	pm.queueNext()
+	pm.mx.Unlock()
+}
+
+// Sleep returns the state of the sleep timer.
+func (pm *PlayManager) Sleep() SleepStatus {
+	pm.sleepMx.Lock()
+	defer pm.sleepMx.Unlock()
+
+	t := pm.sleep
+	if t == nil {
+		return SleepStatus{}
+	}
+	remaining, known := pm.sleepRemaining(t)
+	return SleepStatus{Active: true, Remaining: max(remaining, 0), Known: known, Tracks: t.tracks, Fading: t.fading}
+}
+
+func (pm *PlayManager) startSleep(t *sleepTimer) {
+	pm.sleepMx.Lock()
+	pm.stopSleep()
+	t.done = make(chan struct{})
+	pm.sleep = t
+	pm.sleepMx.Unlock()
+
+	// The last track must not move on to the next one.
+	pm.mx.Lock()
+	pm.queueNext()
+	pm.mx.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(sleepTick)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-t.done:
+				return
+			case <-ticker.C:
+			}
+			if pm.sleepStep(t) {
+				return
+			}
+		}
+	}()
+}
+
+// stopSleep ends the running timer, pm.sleepMx must be held.
+func (pm *PlayManager) stopSleep() {
+	t := pm.sleep
+	if t == nil {
+		return
+	}
+	close(t.done)
+	if t.fading && pm.Player != nil {
+		pm.Player.SetVolume(t.volume)
+	}
+	pm.sleep = nil
+}
+
+// expireSleep pauses playback and ends the timer, pm.sleepMx must be held.
+func (pm *PlayManager) expireSleep() {
+	if pm.Player != nil {
+		pm.Player.Pause()
+	}
+	pm.stopSleep()
+}
+
+// sleepStep starts the fade of t and pauses playback when it is due.
+// It reports whether t ended.
+func (pm *PlayManager) sleepStep(t *sleepTimer) bool {
+	pm.sleepMx.Lock()
+	defer pm.sleepMx.Unlock()
+
+	if pm.sleep != t {
+		return true
+	}
+
+	remaining, known := pm.sleepRemaining(t)
+	if !known {
+		return false
+	}
+	if remaining <= 0 && t.tracks == 0 {
+		pm.expireSleep()
+		return true
+	}
+	// A timer by tracks ends when its last track does, see sleepTrackEnded.
+
+	if t.fade > 0 && remaining <= t.fade && !t.fading && pm.Player != nil {
+		// The Player ramps the volume down over what is left of the timer.
+		t.fading = true
+		t.volume = pm.Player.Info().Volume
+		pm.Player.FadeTo(t.volume-sleepFadeDepth, max(remaining, 0))
+	}
+	return false
+}
+
+// sleepRemaining returns the time until t is due, if it is known yet.
+// pm.sleepMx must be held.
+func (pm *PlayManager) sleepRemaining(t *sleepTimer) (time.Duration, bool) {
+	if t.tracks == 0 {
+		return time.Until(t.deadline), true
+	}
+	if t.tracks > 1 || pm.Player == nil {
+		return 0, false
+	}
+
+	info := pm.Player.Info()
+	if info.Looping {
+		return 0, false // A looping track does not end
+	}
+	return time.Duration(float64(info.Length-info.Current) / info.Speed), true
+}
+
+// sleepTrackEnded counts a track that ended for a timer by tracks.
+// It reports whether that was the last track and playback stopped.
+func (pm *PlayManager) sleepTrackEnded() bool {
+	pm.sleepMx.Lock()
+	defer pm.sleepMx.Unlock()
+
+	t := pm.sleep
+	if t == nil || t.tracks == 0 {
+		return false
+	}
+	if t.tracks--; t.tracks > 0 {
+		return false
+	}
+	pm.expireSleep()
+	return true
+}
+
+// sleepsAfterCurrent reports whether the sleep timer ends with the current
+// track, so no song is queued after it.
+func (pm *PlayManager) sleepsAfterCurrent() bool {
+	pm.sleepMx.Lock()
+	defer pm.sleepMx.Unlock()
+
+	return pm.sleep != nil && pm.sleep.tracks == 1
+}
@@ -0,0 +1,170 @@
+package playmanager
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+	"github.com/tommjj/music_player/internal/player"
+)
+
+// writeTestWAV writes a 440 Hz sine of length d to a 16-bit stereo WAV file.
+func writeTestWAV(t *testing.T, filename string, d time.Duration) {
+	t.Helper()
+
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	const sampleRate beep.SampleRate = 44100
+	i := 0
+	sine := beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		for j := range samples {
+			v := 0.5 * math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate))
+			samples[j] = [2]float64{v, v}
+			i++
+		}
+		return len(samples), true
+	})
+
+	format := beep.Format{SampleRate: sampleRate, NumChannels: 2, Precision: 2}
+	if err := wav.Encode(f, beep.Take(sampleRate.N(d), sine), format); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// eventually waits until cond holds, polling it.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newTestPlayManager returns an auto-playing PlayManager on a realtime
+// NullOutput with songs of length d.
+func newTestPlayManager(t *testing.T, count int, d time.Duration) *PlayManager {
+	t.Helper()
+
+	dir := t.TempDir()
+	songs := make([]*Song, count)
+	for i := range songs {
+		fileName := filepath.Join(dir, string(rune('a'+i))+".wav")
+		writeTestWAV(t, fileName, d)
+		songs[i] = &Song{Title: filepath.Base(fileName), Path: fileName}
+	}
+
+	output := player.NewNullOutput(true)
+	p := player.NewPlayerWithOutput(output)
+	t.Cleanup(func() {
+		p.Close()
+		output.Close()
+	})
+
+	pm := NewPlayManagerWithPlayer(p)
+	pm.AutoPlay = true
+	pm.SetSongs(songs)
+	return pm
+}
+
+func TestPlayManager_SleepAfterTracks(t *testing.T) {
+	pm := newTestPlayManager(t, 3, 300*time.Millisecond)
+
+	var mx sync.Mutex
+	var played []*Song
+	pm.OnPlay = func(song *Song) {
+		mx.Lock()
+		played = append(played, song)
+		mx.Unlock()
+	}
+
+	if err := pm.SleepAfterTracks(0, 0); err == nil {
+		t.Error("SleepAfterTracks(0) succeeded, want an error")
+	}
+	if err := pm.PlayCurrent(); err != nil {
+		t.Fatalf("PlayCurrent failed: %v", err)
+	}
+	if err := pm.SleepAfterTracks(2, 100*time.Millisecond); err != nil {
+		t.Fatalf("SleepAfterTracks failed: %v", err)
+	}
+	if s := pm.Sleep(); !s.Active || s.Tracks != 2 || s.Known {
+		t.Errorf("Sleep() = %+v, want 2 tracks left and no known time", s)
+	}
+
+	eventually(t, "the timer ends", func() bool { return !pm.Sleep().Active })
+	if !pm.Player.IsPaused() {
+		t.Error("IsPaused() = false, want playback paused by the timer")
+	}
+
+	// The third song never starts, the volume is back for the next playback.
+	time.Sleep(400 * time.Millisecond)
+	mx.Lock()
+	defer mx.Unlock()
+	if len(played) != 2 {
+		t.Errorf("played %d songs, want 2", len(played))
+	}
+	if v := pm.Player.Info().Volume; v != 0 {
+		t.Errorf("volume after the timer = %v, want 0", v)
+	}
+}
+
+func TestPlayManager_CancelSleepWhileFading(t *testing.T) {
+	pm := newTestPlayManager(t, 1, 5*time.Second)
+	pm.Player.SetVolume(-1)
+
+	if err := pm.PlayCurrent(); err != nil {
+		t.Fatalf("PlayCurrent failed: %v", err)
+	}
+	pm.SleepAfter(time.Second, 900*time.Millisecond)
+
+	eventually(t, "the fade starts", func() bool { return pm.Sleep().Fading })
+	if v := pm.Player.Info().Volume; v >= -1 {
+		t.Errorf("volume while fading = %v, want below -1", v)
+	}
+
+	pm.CancelSleep()
+	if s := pm.Sleep(); s.Active {
+		t.Errorf("Sleep() after CancelSleep = %+v, want no timer", s)
+	}
+	if v := pm.Player.Info().Volume; v != -1 {
+		t.Errorf("volume after CancelSleep = %v, want -1 restored", v)
+	}
+
+	// The cancelled timer must not pause playback any more.
+	time.Sleep(1200 * time.Millisecond)
+	if pm.Player.IsPaused() {
+		t.Error("IsPaused() = true after the timer was cancelled")
+	}
+}
+
+func TestPlayManager_SleepAfter(t *testing.T) {
+	pm := newTestPlayManager(t, 1, 5*time.Second)
+
+	if err := pm.PlayCurrent(); err != nil {
+		t.Fatalf("PlayCurrent failed: %v", err)
+	}
+	pm.SleepAfter(300*time.Millisecond, 200*time.Millisecond)
+	if s := pm.Sleep(); !s.Active || !s.Known || s.Remaining > 300*time.Millisecond {
+		t.Errorf("Sleep() = %+v, want at most 300ms left", s)
+	}
+
+	eventually(t, "the timer ends", func() bool { return !pm.Sleep().Active })
+	if !pm.Player.IsPaused() {
+		t.Error("IsPaused() = false, want playback paused by the timer")
+	}
+	if v := pm.Player.Info().Volume; v != 0 {
+		t.Errorf("volume after the timer = %v, want 0 restored", v)
+	}
+}
@@ -0,0 +1,210 @@
+package player
+
+import (
+	"math"
+	"math/cmplx"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+const (
+	// analysisSize is the number of samples Levels and Spectrum look at, a
+	// power of 2 for the FFT. At 44.1 kHz it is about 46ms.
+	analysisSize = 2048
+	// analysisStale is how long the analysis waits for new samples before it
+	// reads as silence, the output stops pulling a chain that ended.
+	analysisStale = 250 * time.Millisecond
+	// SpectrumFloor is the lowest magnitude of a Spectrum, in dBFS.
+	SpectrumFloor = -120.0
+)
+
+// Levels are the levels of what the Player plays, per channel, where 1 is
+// full scale.
+type Levels struct {
+	RMS  [2]float64
+	Peak [2]float64
+}
+
+// Spectrum is the spectrum of what the Player plays, both channels mixed.
+type Spectrum struct {
+	SampleRate beep.SampleRate
+	// Magnitudes are in dBFS, a full scale sine reads 0. Bin i is at
+	// Frequency(i), from 0 Hz up to just below half the sample rate.
+	Magnitudes []float64
+}
+
+// Frequency returns the frequency of bin i in Hz.
+func (s Spectrum) Frequency(i int) float64 {
+	return float64(i) * float64(s.SampleRate) / float64(2*len(s.Magnitudes))
+}
+
+// Bands groups the bins from low to high Hz into n bands spaced evenly to the
+// ear, each is the loudest bin in it. A band narrower than a bin takes the
+// nearest bin.
+func (s Spectrum) Bands(n int, low, high float64) []float64 {
+	bands := make([]float64, n)
+	if len(s.Magnitudes) == 0 || n <= 0 || low <= 0 || high <= low {
+		for i := range bands {
+			bands[i] = SpectrumFloor
+		}
+		return bands
+	}
+
+	binWidth := s.Frequency(1)
+	ratio := math.Pow(high/low, 1/float64(n))
+	for i := range bands {
+		from := int(math.Round(low * math.Pow(ratio, float64(i)) / binWidth))
+		to := int(math.Round(low * math.Pow(ratio, float64(i+1)) / binWidth))
+		from = max(0, min(from, len(s.Magnitudes)-1))
+		to = max(from+1, min(to, len(s.Magnitudes)))
+
+		bands[i] = SpectrumFloor
+		for _, m := range s.Magnitudes[from:to] {
+			bands[i] = max(bands[i], m)
+		}
+	}
+	return bands
+}
+
+// analyzer keeps the last analysisSize samples that pass through it. The
+// audio goroutine never waits for a reader: while one copies the window the
+// samples are left out of it.
+type analyzer struct {
+	Streamer beep.Streamer
+
+	mx      sync.Mutex
+	window  [analysisSize][2]float64 // a ring, pos is the oldest sample
+	pos     int
+	updated time.Time
+}
+
+func newAnalyzer(s beep.Streamer) *analyzer {
+	return &analyzer{Streamer: s}
+}
+
+func (a *analyzer) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = a.Streamer.Stream(samples)
+	if n == 0 || !a.mx.TryLock() {
+		return n, ok
+	}
+	for _, s := range samples[max(0, n-analysisSize):n] {
+		a.window[a.pos] = s
+		a.pos = (a.pos + 1) % analysisSize
+	}
+	a.updated = time.Now()
+	a.mx.Unlock()
+	return n, ok
+}
+
+func (a *analyzer) Err() error {
+	return a.Streamer.Err()
+}
+
+// snapshot returns the window oldest first, silence once it went stale.
+func (a *analyzer) snapshot() [][2]float64 {
+	window := make([][2]float64, analysisSize)
+
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	if time.Since(a.updated) > analysisStale {
+		return window
+	}
+	n := copy(window, a.window[a.pos:])
+	copy(window[n:], a.window[:a.pos])
+	return window
+}
+
+// levels measures the levels of window.
+func levels(window [][2]float64) Levels {
+	var l Levels
+	var sum [2]float64
+	for _, s := range window {
+		for c := range 2 {
+			sum[c] += s[c] * s[c]
+			l.Peak[c] = max(l.Peak[c], math.Abs(s[c]))
+		}
+	}
+	for c := range 2 {
+		l.RMS[c] = math.Sqrt(sum[c] / float64(len(window)))
+	}
+	return l
+}
+
+// spectrum returns the magnitudes in dBFS of the Hann windowed FFT of window,
+// whose length must be a power of 2.
+func spectrum(window [][2]float64) []float64 {
+	size := len(window)
+	x := make([]complex128, size)
+	for i, s := range window {
+		hann := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size))
+		x[i] = complex((s[0]+s[1])/2*hann, 0)
+	}
+	fft(x)
+
+	// The Hann window halves a sine, so a full scale one sums to size/4.
+	magnitudes := make([]float64, size/2)
+	for i := range magnitudes {
+		magnitudes[i] = max(20*math.Log10(cmplx.Abs(x[i])/(float64(size)/4)), SpectrumFloor)
+	}
+	return magnitudes
+}
+
+// fft transforms x in place, its length must be a power of 2.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := range size / 2 {
+				even, odd := x[start+k], x[start+k+size/2]*w
+				x[start+k], x[start+k+size/2] = even+odd, even-odd
+				w *= step
+			}
+		}
+	}
+}
+
+// analysis returns the analyzer of the chain that plays, nil if none does.
+// The analyzer is only set with p.mx held, the output need not be locked.
+func (p *Player) analysis() *analyzer {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return p.analyzer
+}
+
+// Levels returns the RMS and peak levels of the last moment of what the
+// Player played, after the volume and the limiter. It is cheap enough to call
+// for every frame of a meter.
+func (p *Player) Levels() Levels {
+	a := p.analysis()
+	if a == nil {
+		return Levels{}
+	}
+	return levels(a.snapshot())
+}
+
+// Spectrum returns the spectrum of the last moment of what the Player played,
+// after the volume and the limiter.
+func (p *Player) Spectrum() Spectrum {
+	a := p.analysis()
+	if a == nil {
+		return Spectrum{SampleRate: p.outputRate, Magnitudes: spectrum(make([][2]float64, analysisSize))}
+	}
+	return Spectrum{SampleRate: p.outputRate, Magnitudes: spectrum(a.snapshot())}
+}
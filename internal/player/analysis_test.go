@@ -0,0 +1,105 @@
+package player
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+func TestAnalyzer(t *testing.T) {
+	// A 1 kHz sine at half scale on the left, a quarter on the right.
+	i := 0
+	a := newAnalyzer(beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		for n = range samples {
+			v := math.Sin(2 * math.Pi * 1000 * float64(i) / 44100)
+			samples[n] = [2]float64{v / 2, v / 4}
+			i++
+		}
+		return len(samples), true
+	}))
+	buf := make([][2]float64, 1000)
+	for range 5 {
+		a.Stream(buf)
+	}
+
+	window := a.snapshot()
+	l := levels(window)
+	for c, want := range []float64{0.5, 0.25} {
+		if math.Abs(l.Peak[c]-want) > 1e-3 {
+			t.Errorf("channel %d peak = %v, want %v", c, l.Peak[c], want)
+		}
+		if rms := want / math.Sqrt2; math.Abs(l.RMS[c]-rms) > 1e-3 {
+			t.Errorf("channel %d RMS = %v, want %v", c, l.RMS[c], rms)
+		}
+	}
+
+	s := Spectrum{SampleRate: 44100, Magnitudes: spectrum(window)}
+	loudest := 0
+	for i, m := range s.Magnitudes {
+		if m > s.Magnitudes[loudest] {
+			loudest = i
+		}
+	}
+	if f := s.Frequency(loudest); math.Abs(f-1000) > s.Frequency(1) {
+		t.Errorf("loudest bin at %v Hz, want 1000 Hz", f)
+	}
+	// Both channels mixed are at 3/8 of full scale.
+	if want := 20 * math.Log10(0.375); math.Abs(s.Magnitudes[loudest]-want) > 1.5 {
+		t.Errorf("magnitude at 1 kHz = %v dBFS, want about %v", s.Magnitudes[loudest], want)
+	}
+	if m := s.Magnitudes[nearestBin(s, 5000)]; m > -40 {
+		t.Errorf("magnitude at 5 kHz = %v dBFS, want well below the sine", m)
+	}
+
+	bands := s.Bands(10, 20, 20000)
+	loudestBand := 0
+	for i, b := range bands {
+		if b > bands[loudestBand] {
+			loudestBand = i
+		}
+	}
+	// 1 kHz is in the 6th of 10 bands between 20 Hz and 20 kHz.
+	if loudestBand != 5 {
+		t.Errorf("loudest band = %d, want 5 in %v", loudestBand, bands)
+	}
+}
+
+// nearestBin returns the bin of s nearest to f Hz.
+func nearestBin(s Spectrum, f float64) int {
+	return int(math.Round(f / s.Frequency(1)))
+}
+
+func TestPlayer_Levels(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "sine.wav")
+	writeTestWAV(t, fileName, 44100, time.Second)
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+
+	if l := player.Levels(); l != (Levels{}) {
+		t.Errorf("Levels() with nothing playing = %+v, want silence", l)
+	}
+	if s := player.Spectrum(); len(s.Magnitudes) != analysisSize/2 || s.Magnitudes[0] != SpectrumFloor {
+		t.Errorf("Spectrum() with nothing playing has %d bins, want %d at the floor", len(s.Magnitudes), analysisSize/2)
+	}
+
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if l := player.Levels(); l.Peak[0] < 0.1 || l.RMS[0] < 0.05 {
+		t.Errorf("Levels() while playing = %+v, want the sine", l)
+	}
+
+	player.Pause()
+	time.Sleep(200 * time.Millisecond)
+	if l := player.Levels(); l.Peak[0] > 1e-3 {
+		t.Errorf("Levels() while paused = %+v, want silence", l)
+	}
+}
@@ -0,0 +1,98 @@
+package player
+
+import (
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// ChannelMode chooses how the Player mixes the left and right channels.
+type ChannelMode string
+
+const (
+	ChannelsStereo  ChannelMode = "stereo"
+	ChannelsMono    ChannelMode = "mono"    // both channels mixed, for a single earbud
+	ChannelsSwap    ChannelMode = "swap"    // left and right swapped
+	ChannelsKaraoke ChannelMode = "karaoke" // what is in the center, usually the vocals, cancelled
+)
+
+// channelGlide is how long a change of the channel mix takes, so it does not click.
+const channelGlide = 20 * time.Millisecond
+
+// channelMatrix mixes a sample into left = m[0]*L + m[1]*R and right = m[2]*L + m[3]*R.
+type channelMatrix [4]float64
+
+// newChannelMatrix returns the mix of mode, with the balance from -1, only
+// the left channel, to 1, only the right one.
+func newChannelMatrix(mode ChannelMode, balance float64) channelMatrix {
+	var m channelMatrix
+	switch mode {
+	case ChannelsMono:
+		m = channelMatrix{0.5, 0.5, 0.5, 0.5}
+	case ChannelsSwap:
+		m = channelMatrix{0, 1, 1, 0}
+	case ChannelsKaraoke:
+		// The difference of the channels, the same on both, leaves out
+		// what is equal on both.
+		m = channelMatrix{0.5, -0.5, 0.5, -0.5}
+	default:
+		m = channelMatrix{1, 0, 0, 1}
+	}
+
+	left, right := min(1, 1-balance), min(1, 1+balance)
+	m[0], m[1] = m[0]*left, m[1]*left
+	m[2], m[3] = m[2]*right, m[3]*right
+	return m
+}
+
+// channelMixer applies a channelMatrix, a new one glides in sample by sample.
+//
+// It must only be touched with the output locked.
+type channelMixer struct {
+	Streamer beep.Streamer
+
+	matrix channelMatrix
+	target channelMatrix
+	step   channelMatrix
+	left   int // samples left of the glide
+}
+
+func newChannelMixer(m channelMatrix, s beep.Streamer) *channelMixer {
+	return &channelMixer{Streamer: s, matrix: m, target: m}
+}
+
+// set glides to m over n samples.
+func (c *channelMixer) set(m channelMatrix, n int) {
+	c.target = m
+	if n <= 0 {
+		c.matrix, c.left = m, 0
+		return
+	}
+	for i := range m {
+		c.step[i] = (m[i] - c.matrix[i]) / float64(n)
+	}
+	c.left = n
+}
+
+func (c *channelMixer) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = c.Streamer.Stream(samples)
+	for i := range samples[:n] {
+		if c.left > 0 {
+			for j := range c.matrix {
+				c.matrix[j] += c.step[j]
+			}
+			if c.left--; c.left == 0 {
+				c.matrix = c.target
+			}
+		}
+
+		l, r := samples[i][0], samples[i][1]
+		samples[i][0] = c.matrix[0]*l + c.matrix[1]*r
+		samples[i][1] = c.matrix[2]*l + c.matrix[3]*r
+	}
+	return n, ok
+}
+
+func (c *channelMixer) Err() error {
+	return c.Streamer.Err()
+}
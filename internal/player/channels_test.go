@@ -0,0 +1,104 @@
+package player
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+func TestChannelMixer(t *testing.T) {
+	constant := beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		for i := range samples {
+			samples[i] = [2]float64{1, 0.5}
+		}
+		return len(samples), true
+	})
+
+	tests := []struct {
+		mode    ChannelMode
+		balance float64
+		want    [2]float64
+	}{
+		{ChannelsStereo, 0, [2]float64{1, 0.5}},
+		{ChannelsMono, 0, [2]float64{0.75, 0.75}},
+		{ChannelsSwap, 0, [2]float64{0.5, 1}},
+		{ChannelsKaraoke, 0, [2]float64{0.25, 0.25}},
+		{ChannelsStereo, -0.5, [2]float64{1, 0.25}},
+		{ChannelsStereo, 1, [2]float64{0, 0.5}},
+		{ChannelsMono, 0.5, [2]float64{0.375, 0.75}},
+	}
+	for _, tt := range tests {
+		c := newChannelMixer(newChannelMatrix(tt.mode, tt.balance), constant)
+		buf := make([][2]float64, 1)
+		c.Stream(buf)
+		if math.Abs(buf[0][0]-tt.want[0]) > 1e-9 || math.Abs(buf[0][1]-tt.want[1]) > 1e-9 {
+			t.Errorf("%v with balance %v = %v, want %v", tt.mode, tt.balance, buf[0], tt.want)
+		}
+	}
+
+	// A change glides in and lands on the new mix.
+	c := newChannelMixer(newChannelMatrix(ChannelsStereo, 0), constant)
+	c.set(newChannelMatrix(ChannelsSwap, 0), 100)
+	buf := make([][2]float64, 100)
+	c.Stream(buf)
+	if buf[0][0] < 0.99 || buf[49][0] > 0.8 || buf[49][0] < 0.7 || buf[99] != [2]float64{0.5, 1} {
+		t.Errorf("glide = %v, %v, %v, want from %v over %v to %v", buf[0], buf[49], buf[99], [2]float64{1, 0.5}, [2]float64{0.75, 0.75}, [2]float64{0.5, 1})
+	}
+}
+
+func TestPlayer_Channels(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "sine.wav")
+	writeTestWAV(t, fileName, 44100, time.Second)
+
+	output := &steppedOutput{}
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+
+	// channelPeaks renders fileName and returns the peak of every channel.
+	channelPeaks := func() [2]float64 {
+		t.Helper()
+		if err := player.Play(fileName); err != nil {
+			t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+		}
+		samples := output.render(t)
+		if len(samples) != 44100 {
+			t.Fatalf("rendered %d samples, want 44100", len(samples))
+		}
+		var peaks [2]float64
+		for _, s := range samples {
+			peaks[0], peaks[1] = max(peaks[0], math.Abs(s[0])), max(peaks[1], math.Abs(s[1]))
+		}
+		return peaks
+	}
+
+	// The test sine plays on both channels in stereo.
+	if peaks := channelPeaks(); peaks[0] < 0.2 || peaks[1] < 0.2 {
+		t.Fatalf("stereo peaks = %v, want the sine on both channels", peaks)
+	}
+
+	if err := player.SetChannelMode("surround"); err == nil {
+		t.Error("SetChannelMode(surround) succeeded, want an error")
+	}
+	if err := player.SetBalance(1.5); err == nil {
+		t.Error("SetBalance(1.5) succeeded, want an error")
+	}
+	if err := player.SetChannelMode(ChannelsKaraoke); err != nil {
+		t.Fatalf("SetChannelMode failed: %v", err)
+	}
+	if err := player.SetBalance(-0.5); err != nil {
+		t.Fatalf("SetBalance failed: %v", err)
+	}
+
+	// The settings stay for the next track. The sine is the same on both
+	// channels, karaoke cancels all of it.
+	peaks := channelPeaks()
+	if info := player.Info(); info.ChannelMode != ChannelsKaraoke || info.Balance != -0.5 {
+		t.Errorf("Info() = %v with balance %v, want %v with -0.5", info.ChannelMode, info.Balance, ChannelsKaraoke)
+	}
+	if peaks[0] > 1e-3 || peaks[1] > 1e-3 {
+		t.Errorf("karaoke peaks = %v, want silence", peaks)
+	}
+}
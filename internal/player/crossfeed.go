@@ -0,0 +1,139 @@
+package player
+
+import (
+	"math"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// CrossfeedPreset chooses how much of each channel the crossfeed feeds into
+// the other one, the presets are those of bs2b.
+type CrossfeedPreset string
+
+const (
+	CrossfeedOff    CrossfeedPreset = "off"
+	CrossfeedLight  CrossfeedPreset = "light"  // 650 Hz, 9.5 dB, after Jan Meier
+	CrossfeedMedium CrossfeedPreset = "medium" // 700 Hz, 6 dB, after Chu Moy
+	CrossfeedStrong CrossfeedPreset = "strong" // 700 Hz, 4.5 dB, close to a virtual speaker setup
+)
+
+// crossfeedGlide is how long the crossfeed takes to come in or go away, so it does not click.
+const crossfeedGlide = 50 * time.Millisecond
+
+// crossfeedParams returns the cut frequency of the fed channel in Hz and how
+// much quieter the feed is than the channel it is fed into, in dB. The less
+// quieter, the stronger the crossfeed.
+func crossfeedParams(preset CrossfeedPreset) (cut, feed float64) {
+	switch preset {
+	case CrossfeedLight:
+		return 650, 9.5
+	case CrossfeedMedium:
+		return 700, 6
+	default:
+		return 700, 4.5
+	}
+}
+
+// crossfeed mixes a low passed copy of each channel into the other one, the
+// way a speaker is also heard by the far ear, and lowers the highs of the
+// direct channel to keep the overall tone. It is the filter of bs2b.
+//
+// The filters always run, so turning it on glides in without a transient.
+//
+// It must only be touched with the output locked.
+type crossfeed struct {
+	Streamer beep.Streamer
+	rate     beep.SampleRate
+
+	a0Lo, b1Lo       float64 // the low pass of the feed
+	a0Hi, a1Hi, b1Hi float64 // the high shelf of the direct channel
+	gain             float64
+
+	lo, hi, last [2]float64
+
+	wet      float64 // from 0, off, to 1
+	wetStep  float64
+	wetLeft  int
+	wetAfter float64
+}
+
+func newCrossfeed(rate beep.SampleRate, preset CrossfeedPreset, s beep.Streamer) *crossfeed {
+	c := &crossfeed{Streamer: s, rate: rate}
+	c.design(CrossfeedLight) // the filters run while it is off, too
+	c.set(preset, 0)
+	return c
+}
+
+// set switches to preset, fading in or out over n samples.
+func (c *crossfeed) set(preset CrossfeedPreset, n int) {
+	if preset != CrossfeedOff {
+		c.design(preset)
+	}
+
+	target := 1.0
+	if preset == CrossfeedOff {
+		target = 0
+	}
+	if n <= 0 {
+		c.wet, c.wetLeft = target, 0
+		return
+	}
+	c.wetStep = (target - c.wet) / float64(n)
+	c.wetLeft = n
+	c.wetAfter = target
+}
+
+// design computes the filters of preset as libbs2b does.
+func (c *crossfeed) design(preset CrossfeedPreset) {
+	cut, feed := crossfeedParams(preset)
+
+	gainLo := feed*-5/6 - 3
+	gainHi := feed/6 - 3
+	gLo := math.Pow(10, gainLo/20)
+	gHi := 1 - math.Pow(10, gainHi/20)
+	cutHi := cut * math.Pow(2, (gainLo-20*math.Log10(gHi))/12)
+
+	x := math.Exp(-2 * math.Pi * cut / float64(c.rate))
+	c.b1Lo = x
+	c.a0Lo = gLo * (1 - x)
+
+	x = math.Exp(-2 * math.Pi * cutHi / float64(c.rate))
+	c.b1Hi = x
+	c.a0Hi = 1 - gHi*(1-x)
+	c.a1Hi = -x
+
+	c.gain = 1 / (1 - gHi + gLo)
+}
+
+func (c *crossfeed) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = c.Streamer.Stream(samples)
+	for i := range samples[:n] {
+		in := samples[i]
+		for ch := range 2 {
+			c.lo[ch] = c.a0Lo*in[ch] + c.b1Lo*c.lo[ch]
+			c.hi[ch] = c.a0Hi*in[ch] + c.a1Hi*c.last[ch] + c.b1Hi*c.hi[ch]
+		}
+		c.last = in
+
+		if c.wetLeft > 0 {
+			c.wet += c.wetStep
+			if c.wetLeft--; c.wetLeft == 0 {
+				c.wet = c.wetAfter
+			}
+		}
+		if c.wet == 0 {
+			continue
+		}
+
+		left := (c.hi[0] + c.lo[1]) * c.gain
+		right := (c.hi[1] + c.lo[0]) * c.gain
+		samples[i][0] = in[0] + (left-in[0])*c.wet
+		samples[i][1] = in[1] + (right-in[1])*c.wet
+	}
+	return n, ok
+}
+
+func (c *crossfeed) Err() error {
+	return c.Streamer.Err()
+}
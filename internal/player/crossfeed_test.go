@@ -0,0 +1,96 @@
+package player
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// crossfeedSine runs a sine of freq on the left channel only through a
+// crossfeed with preset, and returns the peaks of both channels once it settled.
+func crossfeedSine(preset CrossfeedPreset, freq float64) (left, right float64) {
+	i := 0
+	c := newCrossfeed(44100, preset, beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		for j := range samples {
+			samples[j] = [2]float64{0.5 * math.Sin(2*math.Pi*freq*float64(i)/44100), 0}
+			i++
+		}
+		return len(samples), true
+	}))
+
+	buf := make([][2]float64, 44100/2)
+	c.Stream(buf)
+	c.Stream(buf)
+	for _, s := range buf {
+		left, right = max(left, math.Abs(s[0])), max(right, math.Abs(s[1]))
+	}
+	return left, right
+}
+
+func TestCrossfeed(t *testing.T) {
+	if left, right := crossfeedSine(CrossfeedOff, 100); math.Abs(left-0.5) > 1e-3 || right != 0 {
+		t.Errorf("off: peaks = %v, %v, want 0.5, 0", left, right)
+	}
+
+	var last float64
+	for _, preset := range []CrossfeedPreset{CrossfeedLight, CrossfeedMedium, CrossfeedStrong} {
+		_, feed := crossfeedParams(preset)
+
+		// The lows are fed to the other ear feed dB below the direct ones.
+		left, right := crossfeedSine(preset, 100)
+		got := 20 * math.Log10(left/right)
+		if math.Abs(got-feed) > 1.5 {
+			t.Errorf("%v: feed at 100 Hz = %.1f dB, want about %v", preset, got, feed)
+		}
+		if right <= last {
+			t.Errorf("%v: feed %v, want more than the lighter preset with %v", preset, right, last)
+		}
+		last = right
+
+		// The highs mostly stay on their side.
+		left, right = crossfeedSine(preset, 8000)
+		if right > left/5 {
+			t.Errorf("%v: peaks at 8 kHz = %v, %v, want little on the right", preset, left, right)
+		}
+	}
+}
+
+func TestPlayer_Crossfeed(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "sine.wav")
+	writeTestWAV(t, fileName, 44100, time.Second)
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+
+	if got := player.Crossfeed(); got != CrossfeedOff {
+		t.Errorf("Crossfeed() = %v, want %v by default", got, CrossfeedOff)
+	}
+	if err := player.SetCrossfeed("loud"); err == nil {
+		t.Error("SetCrossfeed(loud) succeeded, want an error")
+	}
+	if err := player.SetCrossfeed(CrossfeedMedium); err != nil {
+		t.Fatalf("SetCrossfeed failed: %v", err)
+	}
+
+	// The setting stays across Play calls and can change while playing.
+	for range 2 {
+		if err := player.Play(fileName); err != nil {
+			t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+		}
+		if got := player.Info().Crossfeed; got != CrossfeedMedium {
+			t.Errorf("Info().Crossfeed = %v, want %v", got, CrossfeedMedium)
+		}
+	}
+	if err := player.SetCrossfeed(CrossfeedOff); err != nil {
+		t.Fatalf("SetCrossfeed failed: %v", err)
+	}
+	if got := player.Info().Crossfeed; got != CrossfeedOff {
+		t.Errorf("Info().Crossfeed = %v, want %v", got, CrossfeedOff)
+	}
+}
@@ -0,0 +1,134 @@
+package player
+
+import (
+	"github.com/faiface/beep"
+)
+
+// Effect builds a stage of the effects chain of a Player. It wraps s, the
+// audio of the stages before it at the sample rate rate, and returns the
+// audio with the effect applied.
+//
+// It is called for every playback started with Play and whenever the effect
+// is enabled, so each stage starts with a fresh state. A stage keeps its
+// state while the Player moves on to queued tracks. The returned Streamer is
+// only streamed with the output locked, and Effect may be called with it
+// locked, so it must return quickly.
+type Effect func(rate beep.SampleRate, s beep.Streamer) beep.Streamer
+
+// The built-in effects of the chain. They are in the chain of a new Player,
+// in this order, have no Effect and are set up with their own methods, like
+// SetEQ. They can be moved, disabled and removed like any other effect.
+const (
+	EffectCrossfeed = "crossfeed"
+	EffectEQ        = "eq"
+	EffectLimiter   = "limiter"
+)
+
+// ChainEffect is an effect in the effects chain of a Player.
+type ChainEffect struct {
+	Name    string
+	Effect  Effect // nil for the built-in effects
+	Enabled bool
+}
+
+func defaultEffects() []ChainEffect {
+	return []ChainEffect{
+		{Name: EffectCrossfeed, Enabled: true},
+		{Name: EffectEQ, Enabled: true},
+		{Name: EffectLimiter, Enabled: true},
+	}
+}
+
+func isBuiltinEffect(name string) bool {
+	return name == EffectCrossfeed || name == EffectEQ || name == EffectLimiter
+}
+
+// effectInput is the input of a stage, it is pointed at the stage before it
+// whenever the chain changes.
+type effectInput struct {
+	s beep.Streamer
+}
+
+func (in *effectInput) Stream(samples [][2]float64) (n int, ok bool) {
+	return in.s.Stream(samples)
+}
+
+func (in *effectInput) Err() error {
+	return in.s.Err()
+}
+
+type effectStage struct {
+	input effectInput
+	out   beep.Streamer
+}
+
+// effectChain runs the enabled effects in order, after the volume.
+//
+// It must only be touched with the output locked.
+type effectChain struct {
+	Streamer beep.Streamer
+	rate     beep.SampleRate
+	builtin  func(name string, s beep.Streamer) beep.Streamer // builds the built-in effects
+
+	stages map[string]*effectStage // the running stages by name
+	out    beep.Streamer
+}
+
+func newEffectChain(rate beep.SampleRate, effects []ChainEffect, builtin func(string, beep.Streamer) beep.Streamer, s beep.Streamer) *effectChain {
+	c := &effectChain{Streamer: s, rate: rate, builtin: builtin}
+	c.update(effects)
+	return c
+}
+
+// update rewires the chain to the enabled effects of effects, in order.
+// Stages that already run keep their state, the others are built. A stage
+// built without a Streamer passes its input through.
+func (c *effectChain) update(effects []ChainEffect) {
+	running := c.stages
+	c.stages = make(map[string]*effectStage)
+	c.out = c.Streamer
+	for _, e := range effects {
+		if !e.Enabled {
+			continue
+		}
+		stage, ok := running[e.Name]
+		if !ok {
+			stage = &effectStage{}
+			if e.Effect != nil {
+				stage.out = e.Effect(c.rate, &stage.input)
+			} else {
+				stage.out = c.builtin(e.Name, &stage.input)
+			}
+			if stage.out == nil {
+				stage.out = &stage.input
+			}
+		}
+		stage.input.s = c.out
+		c.out = stage.out
+		c.stages[e.Name] = stage
+	}
+}
+
+// restart drops the running stages of the effects other than the built-in
+// ones, so the next update builds them again.
+func (c *effectChain) restart() {
+	for name := range c.stages {
+		if !isBuiltinEffect(name) {
+			delete(c.stages, name)
+		}
+	}
+}
+
+// running reports whether the effect called name is running in the chain.
+func (c *effectChain) running(name string) bool {
+	_, ok := c.stages[name]
+	return ok
+}
+
+func (c *effectChain) Stream(samples [][2]float64) (n int, ok bool) {
+	return c.out.Stream(samples)
+}
+
+func (c *effectChain) Err() error {
+	return c.out.Err()
+}
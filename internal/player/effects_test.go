@@ -0,0 +1,224 @@
+package player
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// gainEffect is an Effect that multiplies the audio by gain and counts the
+// stages it built in built.
+func gainEffect(gain float64, built *int) Effect {
+	return func(rate beep.SampleRate, s beep.Streamer) beep.Streamer {
+		*built++
+		return beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+			n, ok = s.Stream(samples)
+			for i := range samples[:n] {
+				samples[i][0] *= gain
+				samples[i][1] *= gain
+			}
+			return n, ok
+		})
+	}
+}
+
+func TestEffectChain(t *testing.T) {
+	constant := beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		for i := range samples {
+			samples[i] = [2]float64{1, 1}
+		}
+		return len(samples), true
+	})
+
+	// offset adds 1 after the gains before it, so the order shows in the result.
+	offset := func(rate beep.SampleRate, s beep.Streamer) beep.Streamer {
+		return beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+			n, ok = s.Stream(samples)
+			for i := range samples[:n] {
+				samples[i][0]++
+				samples[i][1]++
+			}
+			return n, ok
+		})
+	}
+
+	var built int
+	effects := []ChainEffect{
+		{Name: "double", Effect: gainEffect(2, &built), Enabled: true},
+		{Name: "offset", Effect: offset, Enabled: true},
+	}
+	c := newEffectChain(44100, effects, nil, constant)
+
+	stream := func() float64 {
+		buf := make([][2]float64, 1)
+		c.Stream(buf)
+		return buf[0][0]
+	}
+	if got := stream(); got != 3 {
+		t.Errorf("double then offset = %v, want 3", got)
+	}
+
+	effects[0], effects[1] = effects[1], effects[0]
+	c.update(effects)
+	if got := stream(); got != 4 {
+		t.Errorf("offset then double = %v, want 4", got)
+	}
+	if built != 1 {
+		t.Errorf("built %d stages of the running effect, want it to keep running", built)
+	}
+
+	effects[1].Enabled = false
+	c.update(effects)
+	if got := stream(); got != 2 {
+		t.Errorf("offset with double disabled = %v, want 2", got)
+	}
+	effects[1].Enabled = true
+	c.update(effects)
+	if got := stream(); got != 4 || built != 2 {
+		t.Errorf("double enabled again = %v with %d stages built, want 4 with a fresh stage", got, built)
+	}
+
+	// A restarted effect is built again, with the Effect it has now.
+	effects[1].Effect = gainEffect(3, &built)
+	c.restart()
+	c.update(effects)
+	if got := stream(); got != 6 || built != 3 {
+		t.Errorf("double replaced by triple = %v with %d stages built, want 6 with a fresh stage", got, built)
+	}
+
+	// An effect without a Streamer passes the audio through.
+	none := func(rate beep.SampleRate, s beep.Streamer) beep.Streamer { return nil }
+	c.update(append(effects, ChainEffect{Name: "none", Effect: none, Enabled: true}))
+	if got := stream(); got != 6 {
+		t.Errorf("with an effect without a Streamer = %v, want 6", got)
+	}
+
+	c.update(nil)
+	if got := stream(); got != 1 {
+		t.Errorf("no effects = %v, want 1", got)
+	}
+}
+
+func TestPlayer_Effects(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "sine.wav")
+	writeTestWAV(t, fileName, 44100, 3*time.Second)
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+
+	var built int
+	if err := player.AddEffect("", gainEffect(0, &built)); err == nil {
+		t.Error("AddEffect without a name succeeded, want an error")
+	}
+	if err := player.AddEffect("mute", gainEffect(0, &built)); err != nil {
+		t.Fatalf("AddEffect failed: %v", err)
+	}
+	if err := player.AddEffect("mute", gainEffect(0, &built)); !errors.Is(err, os.ErrExist) {
+		t.Errorf("AddEffect with a taken name = %v, want %v", err, os.ErrExist)
+	}
+	if err := player.AddEffect(EffectEQ, gainEffect(0, &built)); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("AddEffect with the name of a built-in effect = %v, want %v", err, os.ErrInvalid)
+	}
+	if err := player.AddEffect("reverb", nil); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("AddEffect without an effect = %v, want %v", err, os.ErrInvalid)
+	}
+	if err := player.AddEffect("louder", gainEffect(4, &built)); err != nil {
+		t.Fatalf("AddEffect failed: %v", err)
+	}
+	if err := player.MoveEffect("louder", 0); err != nil {
+		t.Fatalf("MoveEffect failed: %v", err)
+	}
+	if err := player.MoveEffect("louder", 5); err == nil {
+		t.Error("MoveEffect past the end succeeded, want an error")
+	}
+	if err := player.SetEffectEnabled("reverb", true); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("SetEffectEnabled of an unknown effect = %v, want %v", err, os.ErrNotExist)
+	}
+	if got := effectNames(player.Effects()); !slices.Equal(got, []string{"louder", EffectCrossfeed, EffectEQ, "mute", EffectLimiter}) {
+		t.Errorf("Effects() = %v, want louder, the built-in effects and mute before the limiter", got)
+	}
+
+	// The effects stay for the next track and run while playing.
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if l := player.Levels(); l.Peak[0] > 1e-3 || l.Peak[1] > 1e-3 {
+		t.Errorf("Levels() with mute = %+v, want silence", l)
+	}
+
+	if err := player.SetEffectEnabled("mute", false); err != nil {
+		t.Fatalf("SetEffectEnabled failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if l := player.Levels(); math.Max(l.Peak[0], l.Peak[1]) < 0.1 {
+		t.Errorf("Levels() with mute disabled = %+v, want the sine", l)
+	}
+
+	if err := player.RemoveEffect("mute"); err != nil {
+		t.Fatalf("RemoveEffect failed: %v", err)
+	}
+	if err := player.RemoveEffect("mute"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("RemoveEffect twice = %v, want %v", err, os.ErrNotExist)
+	}
+	if got := effectNames(player.Effects()); !slices.Equal(got, []string{"louder", EffectCrossfeed, EffectEQ, EffectLimiter}) {
+		t.Errorf("Effects() = %v, want louder and the built-in effects", got)
+	}
+
+	// The limiter catches the peaks of louder only after it.
+	time.Sleep(200 * time.Millisecond)
+	if l := player.Levels(); math.Max(l.Peak[0], l.Peak[1]) > limiterCeiling+1e-3 {
+		t.Errorf("Levels() with the limiter after louder = %+v, want at most %v", l, limiterCeiling)
+	}
+	if err := player.MoveEffect(EffectLimiter, 0); err != nil {
+		t.Fatalf("MoveEffect failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if l := player.Levels(); math.Max(l.Peak[0], l.Peak[1]) < 0.95 {
+		t.Errorf("Levels() with the limiter before louder = %+v, want the louder sine", l)
+	}
+
+	if err := player.RemoveEffect(EffectLimiter); err != nil {
+		t.Fatalf("RemoveEffect failed: %v", err)
+	}
+	if info := player.Info(); info.Limiting {
+		t.Error("Info().Limiting without the limiter = true, want false")
+	}
+	if err := player.AddEffect(EffectLimiter, nil); err != nil {
+		t.Fatalf("AddEffect of the limiter failed: %v", err)
+	}
+	if got := effectNames(player.Effects()); !slices.Equal(got, []string{"louder", EffectCrossfeed, EffectEQ, EffectLimiter}) {
+		t.Errorf("Effects() = %v, want the limiter back at the end", got)
+	}
+
+	// SetEffects plays the Effect it was given, even under a running name.
+	effects := player.Effects()
+	effects[0].Effect = gainEffect(0, &built)
+	if err := player.SetEffects(effects); err != nil {
+		t.Fatalf("SetEffects failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if l := player.Levels(); l.Peak[0] > 1e-3 || l.Peak[1] > 1e-3 {
+		t.Errorf("Levels() with louder set to mute = %+v, want silence", l)
+	}
+}
+
+func effectNames(effects []ChainEffect) []string {
+	var names []string
+	for _, e := range effects {
+		names = append(names, e.Name)
+	}
+	return names
+}
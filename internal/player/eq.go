@@ -0,0 +1,151 @@
+package player
+
+import (
+	"math"
+
+	"github.com/faiface/beep"
+)
+
+// EQBands is the number of bands of the graphic equalizer.
+const EQBands = 10
+
+// MaxEQGain bounds the gain of an equalizer band, in dB either way.
+const MaxEQGain = 12.0
+
+const (
+	eqQ        = math.Sqrt2 // about one octave wide, the spacing of the bands
+	eqBlock    = 64         // samples between steps of a gain change
+	eqGainStep = 0.25       // dB a band moves per block, so changes never click
+)
+
+// EQFrequencies are the center frequencies of the equalizer bands in Hz.
+var EQFrequencies = [EQBands]float64{31, 62, 125, 250, 500, 1000, 2000, 4000, 8000, 16000}
+
+// EQGains are the gains of the equalizer bands in dB, 0 leaves a band untouched.
+type EQGains [EQBands]float64
+
+// clamp limits every gain to ±MaxEQGain.
+func (g EQGains) clamp() EQGains {
+	for i := range g {
+		g[i] = max(-MaxEQGain, min(g[i], MaxEQGain))
+	}
+	return g
+}
+
+// biquad is a second order IIR filter in transposed direct form II, one state per channel.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             [2]float64
+	passThrough        bool
+}
+
+// setPeaking makes the filter a peaking EQ, from the Audio EQ Cookbook. The
+// state is kept while the gain glides, so the change does not click, but not
+// when the filter turns into a pass-through or back, as it no longer fits.
+func (f *biquad) setPeaking(sampleRate, freq, q, gain float64) {
+	passThrough := gain == 0 || freq >= sampleRate/2
+	if passThrough != f.passThrough {
+		f.reset()
+	}
+	f.passThrough = passThrough
+	if passThrough {
+		f.b0, f.b1, f.b2, f.a1, f.a2 = 1, 0, 0, 0, 0
+		return
+	}
+
+	a := math.Pow(10, gain/40)
+	w0 := 2 * math.Pi * freq / sampleRate
+	alpha := math.Sin(w0) / (2 * q)
+	cos := math.Cos(w0)
+
+	a0 := 1 + alpha/a
+	f.b0 = (1 + alpha*a) / a0
+	f.b1 = -2 * cos / a0
+	f.b2 = (1 - alpha*a) / a0
+	f.a1 = -2 * cos / a0
+	f.a2 = (1 - alpha/a) / a0
+}
+
+func (f *biquad) process(x float64, ch int) float64 {
+	y := f.b0*x + f.z1[ch]
+	f.z1[ch] = f.b1*x - f.a1*y + f.z2[ch]
+	f.z2[ch] = f.b2*x - f.a2*y
+	return y
+}
+
+func (f *biquad) reset() {
+	f.z1, f.z2 = [2]float64{}, [2]float64{}
+}
+
+// equalizer is a graphic equalizer of peaking filters at EQFrequencies.
+// Gain changes glide towards the target in small steps.
+//
+// It must only be touched with the output locked.
+type equalizer struct {
+	Streamer   beep.Streamer
+	sampleRate float64
+
+	target  EQGains
+	current EQGains
+	bands   [EQBands]biquad
+}
+
+// newEqualizer creates an equalizer that starts at gains.
+func newEqualizer(sampleRate beep.SampleRate, gains EQGains, s beep.Streamer) *equalizer {
+	e := &equalizer{Streamer: s, sampleRate: float64(sampleRate), target: gains, current: gains}
+	for i := range e.bands {
+		e.bands[i].setPeaking(e.sampleRate, EQFrequencies[i], eqQ, gains[i])
+	}
+	return e
+}
+
+// setGains changes the target gains, the bands move there over a few blocks.
+func (e *equalizer) setGains(gains EQGains) {
+	e.target = gains
+}
+
+func (e *equalizer) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = e.Streamer.Stream(samples)
+
+	for start := 0; start < n; start += eqBlock {
+		if e.current != e.target {
+			e.step()
+		}
+		if e.current == (EQGains{}) {
+			// Flat, the filters would pass the samples through anyway.
+			for i := range e.bands {
+				e.bands[i].reset()
+			}
+			continue
+		}
+
+		for i := start; i < min(start+eqBlock, n); i++ {
+			l, r := samples[i][0], samples[i][1]
+			for b := range e.bands {
+				l = e.bands[b].process(l, 0)
+				r = e.bands[b].process(r, 1)
+			}
+			samples[i] = [2]float64{l, r}
+		}
+	}
+	return n, ok
+}
+
+func (e *equalizer) Err() error {
+	return e.Streamer.Err()
+}
+
+// step moves every band one step towards its target gain.
+func (e *equalizer) step() {
+	for i := range e.current {
+		if e.current[i] == e.target[i] {
+			continue
+		}
+		if diff := e.target[i] - e.current[i]; math.Abs(diff) <= eqGainStep {
+			e.current[i] = e.target[i]
+		} else {
+			e.current[i] += math.Copysign(eqGainStep, diff)
+		}
+		e.bands[i].setPeaking(e.sampleRate, EQFrequencies[i], eqQ, e.current[i])
+	}
+}
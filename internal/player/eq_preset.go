@@ -0,0 +1,138 @@
+package player
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+var (
+	ErrEQPresetNotFound = errors.New("EQ preset not found")
+	ErrEQPresetBuiltin  = errors.New("built-in EQ presets cannot be changed")
+)
+
+// EQPreset is a named set of equalizer gains.
+type EQPreset struct {
+	Name  string  `json:"name"`
+	Gains EQGains `json:"gains"`
+}
+
+// BuiltinEQPresets are the presets that come with every EQPresetStore.
+var BuiltinEQPresets = []EQPreset{
+	{Name: "flat"},
+	{Name: "bass boost", Gains: EQGains{6, 5, 4, 2, 0, 0, 0, 0, 0, 0}},
+	{Name: "treble boost", Gains: EQGains{0, 0, 0, 0, 0, 0, 2, 4, 5, 6}},
+	{Name: "vocal", Gains: EQGains{-3, -3, -2, 0, 2, 4, 4, 2, 0, -2}},
+	{Name: "rock", Gains: EQGains{5, 4, 3, 1, -1, -1, 1, 3, 4, 5}},
+	{Name: "pop", Gains: EQGains{-1, 0, 2, 4, 4, 2, 0, -1, -1, -1}},
+	{Name: "jazz", Gains: EQGains{3, 2, 1, 2, -1, -1, 0, 1, 2, 3}},
+	{Name: "classical", Gains: EQGains{4, 3, 2, 1, -1, -1, 0, 2, 3, 4}},
+	{Name: "electronic", Gains: EQGains{5, 4, 1, 0, -2, 1, 0, 1, 4, 5}},
+	{Name: "loudness", Gains: EQGains{6, 4, 0, 0, -2, 0, -1, 0, 4, 2}},
+}
+
+// DefaultEQPresetFile returns where the user presets are kept by default, in
+// the user config directory.
+func DefaultEQPresetFile() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "music_player", "eq_presets.json"), nil
+}
+
+// EQPresetStore keeps the presets a user saved in a JSON file, next to the built-in ones.
+type EQPresetStore struct {
+	filename string
+	user     []EQPreset
+}
+
+// LoadEQPresetStore reads the user presets from filename.
+// A file that does not exist yet holds no presets, it is created on the first Save.
+func LoadEQPresetStore(filename string) (*EQPresetStore, error) {
+	s := &EQPresetStore{filename: filename}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.user); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Presets returns the built-in presets followed by the user presets.
+func (s *EQPresetStore) Presets() []EQPreset {
+	return append(slices.Clone(BuiltinEQPresets), s.user...)
+}
+
+// Preset returns the preset called name.
+func (s *EQPresetStore) Preset(name string) (EQPreset, error) {
+	for _, preset := range s.Presets() {
+		if preset.Name == name {
+			return preset, nil
+		}
+	}
+	return EQPreset{}, ErrEQPresetNotFound
+}
+
+// Save stores gains as the user preset name, replacing a preset with the same name.
+func (s *EQPresetStore) Save(name string, gains EQGains) error {
+	if isBuiltinEQPreset(name) {
+		return ErrEQPresetBuiltin
+	}
+
+	preset := EQPreset{Name: name, Gains: gains.clamp()}
+	if i := s.index(name); i >= 0 {
+		s.user[i] = preset
+	} else {
+		s.user = append(s.user, preset)
+	}
+	return s.write()
+}
+
+// Delete removes the user preset name.
+func (s *EQPresetStore) Delete(name string) error {
+	if isBuiltinEQPreset(name) {
+		return ErrEQPresetBuiltin
+	}
+
+	i := s.index(name)
+	if i < 0 {
+		return ErrEQPresetNotFound
+	}
+	s.user = slices.Delete(s.user, i, i+1)
+	return s.write()
+}
+
+func (s *EQPresetStore) index(name string) int {
+	return slices.IndexFunc(s.user, func(p EQPreset) bool { return p.Name == name })
+}
+
+// write saves the user presets, through a temporary file so a failed write keeps the old ones.
+func (s *EQPresetStore) write() error {
+	data, err := json.MarshalIndent(s.user, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.filename), 0o755); err != nil {
+		return err
+	}
+	tmp := s.filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.filename)
+}
+
+func isBuiltinEQPreset(name string) bool {
+	return slices.ContainsFunc(BuiltinEQPresets, func(p EQPreset) bool { return p.Name == name })
+}
@@ -0,0 +1,166 @@
+package player
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// sineStreamer returns an endless sine of freq Hz with an amplitude of 0.25.
+func sineStreamer(sampleRate beep.SampleRate, freq float64) beep.Streamer {
+	i := 0
+	return beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		for j := range samples {
+			v := 0.25 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate))
+			samples[j] = [2]float64{v, v}
+			i++
+		}
+		return len(samples), true
+	})
+}
+
+// rms returns the root mean square of the left channel.
+func rms(samples [][2]float64) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += s[0] * s[0]
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+func TestEqualizer_Response(t *testing.T) {
+	var gains EQGains
+	gains[5] = 6 // 1 kHz
+
+	for _, tt := range []struct {
+		freq float64
+		gain float64 // dB
+	}{
+		{1000, 6},
+		{31, 0},
+		{16000, 0},
+	} {
+		eq := newEqualizer(44100, gains, sineStreamer(44100, tt.freq))
+
+		samples := make([][2]float64, 44100)
+		eq.Stream(samples) // let the filters settle
+		eq.Stream(samples)
+
+		got := 20 * math.Log10(rms(samples)/(0.25/math.Sqrt2))
+		if math.Abs(got-tt.gain) > 0.5 {
+			t.Errorf("gain at %v Hz = %.2f dB, want %v dB", tt.freq, got, tt.gain)
+		}
+	}
+}
+
+func TestBiquad_PassThrough(t *testing.T) {
+	var f biquad
+	f.setPeaking(44100, 1000, eqQ, 12)
+	for i := range 100 {
+		f.process(math.Sin(float64(i)), 0)
+		f.process(math.Cos(float64(i)), 1)
+	}
+
+	// The state of the boost must not leak into the pass-through.
+	f.setPeaking(44100, 1000, eqQ, 0)
+	for i := range 3 {
+		if l, r := f.process(0, 0), f.process(0, 1); l != 0 || r != 0 {
+			t.Fatalf("sample %d of silence through the pass-through = %v, %v, want 0", i, l, r)
+		}
+	}
+}
+
+func TestEqualizer_Glide(t *testing.T) {
+	eq := newEqualizer(44100, EQGains{}, sineStreamer(44100, 440))
+
+	var gains EQGains
+	gains[0] = MaxEQGain
+	eq.setGains(gains)
+
+	eq.Stream(make([][2]float64, eqBlock))
+	if eq.current[0] != eqGainStep {
+		t.Errorf("gain after one block = %v, want %v", eq.current[0], eqGainStep)
+	}
+
+	eq.Stream(make([][2]float64, eqBlock*int(MaxEQGain/eqGainStep)))
+	if eq.current != gains {
+		t.Errorf("gains = %v, want %v", eq.current, gains)
+	}
+}
+
+func TestPlayer_EQSurvivesPlay(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.wav")
+	second := filepath.Join(dir, "second.wav")
+	writeTestWAV(t, first, 44100, time.Second)
+	writeTestWAV(t, second, 44100, time.Second)
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+
+	gains := EQGains{20, -3}
+	if err := player.Play(first); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", first, err)
+	}
+	player.SetEQ(gains)
+	if err := player.SetEQBand(EQBands, 1); err == nil {
+		t.Errorf("SetEQBand(%d) succeeded, want an error", EQBands)
+	}
+	if err := player.Play(second); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", second, err)
+	}
+
+	want := EQGains{MaxEQGain, -3}
+	if got := player.EQ(); got != want {
+		t.Errorf("EQ() = %v, want %v", got, want)
+	}
+	output.Lock()
+	if player.eq.current != want {
+		t.Errorf("equalizer of the next track starts at %v, want %v", player.eq.current, want)
+	}
+	output.Unlock()
+}
+
+func TestEQPresetStore(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "config", "eq.json")
+
+	store, err := LoadEQPresetStore(fileName)
+	if err != nil {
+		t.Fatalf("LoadEQPresetStore(%s) failed: %v", fileName, err)
+	}
+	if len(store.Presets()) != len(BuiltinEQPresets) {
+		t.Errorf("new store has %d presets, want %d", len(store.Presets()), len(BuiltinEQPresets))
+	}
+
+	if err := store.Save("rock", EQGains{}); !errors.Is(err, ErrEQPresetBuiltin) {
+		t.Errorf("Save(rock) = %v, want %v", err, ErrEQPresetBuiltin)
+	}
+	if err := store.Save("mine", EQGains{1, 2, 3}); err != nil {
+		t.Fatalf("Save(mine) failed: %v", err)
+	}
+	if err := store.Save("other", EQGains{4}); err != nil {
+		t.Fatalf("Save(other) failed: %v", err)
+	}
+	if err := store.Delete("other"); err != nil {
+		t.Fatalf("Delete(other) failed: %v", err)
+	}
+
+	store, err = LoadEQPresetStore(fileName)
+	if err != nil {
+		t.Fatalf("LoadEQPresetStore(%s) failed: %v", fileName, err)
+	}
+	preset, err := store.Preset("mine")
+	if err != nil || preset.Gains != (EQGains{1, 2, 3}) {
+		t.Errorf("Preset(mine) = %v, %v, want the saved gains", preset, err)
+	}
+	if _, err := store.Preset("other"); !errors.Is(err, ErrEQPresetNotFound) {
+		t.Errorf("Preset(other) = %v, want %v", err, ErrEQPresetNotFound)
+	}
+}
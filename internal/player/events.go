@@ -0,0 +1,84 @@
+package player
+
+import (
+	"sync"
+	"time"
+)
+
+// eventBuffer is how many events a subscriber can fall behind before it misses some.
+const eventBuffer = 64
+
+// EventType is the kind of an Event.
+type EventType string
+
+const (
+	EventStarted       EventType = "started"        // a track started, by Play or by moving on to the queued one
+	EventPaused        EventType = "paused"         // playback was paused
+	EventResumed       EventType = "resumed"        // playback was resumed
+	EventSeeked        EventType = "seeked"         // the position was moved, see Event.Position
+	EventVolumeChanged EventType = "volume-changed" // the volume was changed, see Event.Volume
+	EventTrackEnded    EventType = "track-ended"    // a track played to its end, or into the crossfade to the next one
+	EventDecodeError   EventType = "decode-error"   // a track could not be opened or failed while decoding, see Event.Err
+)
+
+// Event is something that happened to the playback of a Player.
+type Event struct {
+	Type     EventType
+	Filepath string        // the file of the track the event is about, empty if it is not a file
+	Name     string        // the display name of the Source of the track
+	Position time.Duration // the new position for EventSeeked, where decoding failed for EventDecodeError
+	Volume   float64       // the new volume for EventVolumeChanged
+	Err      error         // the error for EventDecodeError, a *DecodeError if it failed mid-stream
+}
+
+// eventHub hands events to every subscriber. Publishing never blocks, events
+// for a subscriber that fell eventBuffer events behind are dropped.
+type eventHub struct {
+	mx     sync.Mutex
+	nextID int
+	subs   map[int]chan Event
+}
+
+func (h *eventHub) subscribe() (<-chan Event, func()) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	if h.subs == nil {
+		h.subs = map[int]chan Event{}
+	}
+	id := h.nextID
+	h.nextID++
+	ch := make(chan Event, eventBuffer)
+	h.subs[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mx.Lock()
+			defer h.mx.Unlock()
+
+			delete(h.subs, id)
+			close(ch)
+		})
+	}
+}
+
+func (h *eventHub) publish(e Event) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	for _, ch := range h.subs {
+		select {
+		case ch <- e:
+		default: // The subscriber is not keeping up.
+		}
+	}
+}
+
+// Subscribe returns a channel that receives the events of the Player, and a
+// function that ends the subscription and closes the channel. Any number of
+// subscribers can observe the same Player. Events are sent without waiting,
+// a subscriber that does not keep up misses events instead of holding up playback.
+func (p *Player) Subscribe() (events <-chan Event, cancel func()) {
+	return p.events.subscribe()
+}
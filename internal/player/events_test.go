@@ -0,0 +1,133 @@
+package player
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// nextEvent waits for the next event on events.
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return Event{}
+	}
+}
+
+func TestPlayer_Events(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "sine.wav")
+	writeTestWAV(t, fileName, 44100, time.Second)
+	badName := filepath.Join(dir, "bad.wav")
+	if err := os.WriteFile(badName, []byte("RIFF\x00\x00\x00\x00WAVEjunk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+
+	// Two subscribers see the same events.
+	first, cancelFirst := player.Subscribe()
+	second, cancelSecond := player.Subscribe()
+	defer cancelSecond()
+
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	player.Pause()
+	player.Pause() // already paused, no event
+	player.Resume()
+	if err := player.ToPosition(500 * time.Millisecond); err != nil {
+		t.Fatalf("ToPosition failed: %v", err)
+	}
+	player.SetVolume(-1)
+
+	want := []Event{
+		{Type: EventStarted, Filepath: fileName, Name: fileName},
+		{Type: EventPaused, Filepath: fileName, Name: fileName},
+		{Type: EventResumed, Filepath: fileName, Name: fileName},
+		{Type: EventSeeked, Filepath: fileName, Name: fileName, Position: 500 * time.Millisecond},
+		{Type: EventVolumeChanged, Volume: -1},
+		{Type: EventTrackEnded, Filepath: fileName, Name: fileName},
+	}
+	for _, events := range []<-chan Event{first, second} {
+		for _, w := range want {
+			if got := nextEvent(t, events); got != w {
+				t.Errorf("event = %+v, want %+v", got, w)
+			}
+		}
+	}
+
+	cancelFirst()
+	if _, ok := <-first; ok {
+		t.Error("channel is still open after cancel")
+	}
+
+	if err := player.Play(badName); err == nil {
+		t.Fatalf("Player.Play(%s) succeeded, want an error", badName)
+	}
+	if got := nextEvent(t, second); got.Type != EventDecodeError || got.Filepath != badName || got.Err == nil {
+		t.Errorf("event = %+v, want a %v for %s", got, EventDecodeError, badName)
+	}
+}
+
+func TestPlayer_DecodeError(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "truncated.flac")
+	writeTestFLAC(t, fileName, 44100, 16, time.Second)
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cut the file in the middle of a frame.
+	if err := os.Truncate(fileName, info.Size()/2+7); err != nil {
+		t.Fatal(err)
+	}
+	nextName := filepath.Join(dir, "sine.wav")
+	writeTestWAV(t, nextName, 44100, time.Second)
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+	player.SetOnComplete(func() { t.Error("OnComplete called after a decode error") })
+	player.SetOnAdvance(func(string) { t.Error("OnAdvance called after a decode error") })
+	events, cancel := player.Subscribe()
+	defer cancel()
+
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	if err := player.Queue(nextName); err != nil {
+		t.Fatalf("Player.Queue(%s) failed: %v", nextName, err)
+	}
+
+	for e := nextEvent(t, events); e.Type != EventDecodeError; e = nextEvent(t, events) {
+		if e.Type == EventTrackEnded || e.Type == EventStarted && e.Filepath == nextName {
+			t.Fatalf("event = %+v, want a %v first", e, EventDecodeError)
+		}
+	}
+
+	var decodeErr *DecodeError
+	if err := player.Info().Err; !errors.As(err, &decodeErr) {
+		t.Fatalf("Info().Err = %v, want a *DecodeError", err)
+	}
+	if decodeErr.Filename != fileName {
+		t.Errorf("DecodeError.Filename = %s, want %s", decodeErr.Filename, fileName)
+	}
+	if decodeErr.Position < 300*time.Millisecond || decodeErr.Position > 700*time.Millisecond {
+		t.Errorf("DecodeError.Position = %v, want about 500ms", decodeErr.Position)
+	}
+	time.Sleep(50 * time.Millisecond) // OnComplete would be called by now
+}
@@ -0,0 +1,186 @@
+package player
+
+import (
+	"math"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// volumeGlide is how long SetVolume, VolumeUp and VolumeDown take to reach
+// the new volume, so a change does not click.
+const volumeGlide = 20 * time.Millisecond
+
+// Fades are the lengths of the gain ramps the Player applies so nothing is
+// cut off with a pop. A zero length cuts without a ramp.
+type Fades struct {
+	Pause time.Duration // out on Pause, in on Resume
+	Seek  time.Duration // the crossfade from the old position to the new one
+	Track time.Duration // out when Play or Close stops a track, in when Play starts one
+}
+
+// DefaultFades are the fades of a new Player.
+var DefaultFades = Fades{
+	Pause: 50 * time.Millisecond,
+	Seek:  15 * time.Millisecond,
+	Track: 30 * time.Millisecond,
+}
+
+// fader applies the volume of the Player, in powers of 2 like effects.Volume
+// with Base 2, and a linear gain that ramps in and out to pause and stop.
+// Both ramp sample by sample.
+//
+// It must only be touched with the output locked.
+type fader struct {
+	Streamer beep.Streamer
+
+	volume     float64
+	volumeStep float64
+	volumeLeft int     // samples left of the volume ramp
+	amp        float64 // 2^volume
+
+	gain     float64
+	gainStep float64
+	gainLeft int    // samples left of the gain ramp
+	done     func() // called once the gain ramp completed
+
+	pausing bool // a pause was asked for, the gain is going to 0
+	paused  bool // the gain reached 0, the Streamer is not pulled
+	stopped bool // the gain reached 0 for good, the fader is drained
+
+	// A volume set while pausing waits for the pause, so it cannot jump up
+	// under the fade out.
+	afterPause    float64
+	hasAfterPause bool
+}
+
+func newFader(volume float64, s beep.Streamer) *fader {
+	return &fader{Streamer: s, volume: volume, amp: math.Exp2(volume), gain: 1}
+}
+
+func (f *fader) Stream(samples [][2]float64) (n int, ok bool) {
+	for n < len(samples) {
+		if f.stopped {
+			return n, n > 0
+		}
+		if f.paused {
+			clear(samples[n:])
+			return len(samples), true
+		}
+
+		// Stop pulling where the ramp ends, a pause must not swallow samples.
+		chunk := samples[n:]
+		if f.done != nil && f.gainLeft > 0 {
+			chunk = chunk[:min(len(chunk), f.gainLeft)]
+		}
+		sn, sok := f.Streamer.Stream(chunk)
+		f.apply(chunk[:sn])
+		n += sn
+
+		if !sok && f.gainLeft > 0 {
+			// Nothing left to ramp over.
+			f.gain, f.gainLeft = math.Round(f.gain+f.gainStep*float64(f.gainLeft)), 0
+		}
+		if f.gainLeft == 0 && f.done != nil {
+			done := f.done
+			f.done = nil
+			done()
+		}
+		if !sok {
+			return n, n > 0
+		}
+		if sn == 0 {
+			break
+		}
+	}
+	return n, true
+}
+
+func (f *fader) Err() error {
+	return f.Streamer.Err()
+}
+
+func (f *fader) apply(samples [][2]float64) {
+	for i := range samples {
+		if f.volumeLeft > 0 {
+			f.volume += f.volumeStep
+			f.amp = math.Exp2(f.volume)
+			f.volumeLeft--
+		}
+		if f.gainLeft > 0 {
+			f.gain += f.gainStep
+			if f.gainLeft--; f.gainLeft == 0 {
+				f.gain = math.Round(f.gain) // lands on 0 or 1 exactly
+			}
+		}
+
+		g := f.amp * f.gain
+		samples[i][0] *= g
+		samples[i][1] *= g
+	}
+}
+
+// fadeVolume moves the volume to v over n samples.
+func (f *fader) fadeVolume(v float64, n int) {
+	if f.pausing && !f.paused {
+		f.afterPause, f.hasAfterPause = v, true
+		return
+	}
+	f.hasAfterPause = false
+
+	if n <= 0 {
+		f.volume, f.amp, f.volumeLeft = v, math.Exp2(v), 0
+		return
+	}
+	f.volumeStep = (v - f.volume) / float64(n)
+	f.volumeLeft = n
+}
+
+// fadeGain moves the gain to target over n samples and calls done after.
+func (f *fader) fadeGain(target float64, n int, done func()) {
+	f.done = nil
+	if n <= 0 {
+		f.gain, f.gainLeft = target, 0
+		if done != nil {
+			done()
+		}
+		return
+	}
+	f.gainStep = (target - f.gain) / float64(n)
+	f.gainLeft = n
+	f.done = done
+}
+
+// pause fades out over n samples, then holds the Streamer.
+func (f *fader) pause(n int) {
+	f.pausing = true
+	f.fadeGain(0, n, func() {
+		f.paused = true
+		if f.hasAfterPause {
+			f.hasAfterPause = false
+			f.fadeVolume(f.afterPause, 0)
+		}
+	})
+}
+
+// resume fades back in over n samples, also from the middle of a pause.
+func (f *fader) resume(n int) {
+	f.pausing, f.paused = false, false
+	if f.hasAfterPause {
+		f.hasAfterPause = false
+		f.fadeVolume(f.afterPause, n)
+	}
+	f.fadeGain(1, n, nil)
+}
+
+// stop fades out over n samples, calls done and drains the fader.
+// A paused fader stops at once.
+func (f *fader) stop(n int, done func()) {
+	if f.paused {
+		n = 0
+	}
+	f.fadeGain(0, n, func() {
+		f.stopped = true
+		done()
+	})
+}
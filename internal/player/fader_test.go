@@ -0,0 +1,166 @@
+package player
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+func TestFader(t *testing.T) {
+	// A constant source that counts what was pulled from it.
+	pulled := 0
+	source := beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		n = min(len(samples), 10000-pulled)
+		for i := range samples[:n] {
+			samples[i] = [2]float64{1, 1}
+		}
+		pulled += n
+		return n, n > 0
+	})
+	f := newFader(0, source)
+	buf := make([][2]float64, 512)
+
+	f.Stream(buf[:100])
+	f.pause(100)
+	n, ok := f.Stream(buf)
+	if n != len(buf) || !ok {
+		t.Fatalf("Stream while pausing = %d, %v, want %d, true", n, ok, len(buf))
+	}
+	for i := 1; i < 100; i++ {
+		if buf[i][0] >= buf[i-1][0] {
+			t.Fatalf("sample %d of the pause = %v, want it below %v", i, buf[i][0], buf[i-1][0])
+		}
+	}
+	if p := peak(buf[100:]); p != 0 {
+		t.Errorf("peak after the pause = %v, want silence", p)
+	}
+	// The pause must not swallow samples of the source.
+	if pulled != 200 {
+		t.Errorf("pulled %d samples, want 200", pulled)
+	}
+
+	// A volume set while pausing waits for the pause, then applies on resume.
+	f.resume(100)
+	f.fadeVolume(-1, 100)
+	f.Stream(buf[:200])
+	if buf[0][0] > 0.02 || math.Abs(buf[199][0]-0.5) > 1e-9 {
+		t.Errorf("resume = %v ... %v, want a ramp from 0 to 0.5", buf[0][0], buf[199][0])
+	}
+
+	done := false
+	f.stop(50, func() { done = true })
+	n, ok = f.Stream(buf)
+	if n != 50 || !ok || !done {
+		t.Errorf("Stream while stopping = %d, %v, done %v, want 50, true, true", n, ok, done)
+	}
+	if n, ok := f.Stream(buf); n != 0 || ok {
+		t.Errorf("Stream after stop = %d, %v, want 0, false", n, ok)
+	}
+}
+
+func TestPlayer_FadeTo(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "sine.wav")
+	writeTestWAV(t, fileName, 44100, time.Second)
+
+	// The track fades in over Fades.Track.
+	samples := renderWAV(t, fileName, func(p *Player) {
+		if err := p.SetFades(Fades{Track: 100 * time.Millisecond}); err != nil {
+			t.Fatalf("SetFades failed: %v", err)
+		}
+	})
+	fadeIn := DefaultSampleRate.N(100 * time.Millisecond)
+	if first, rest := peak(samples[:fadeIn/10]), peak(samples[fadeIn:]); first > rest/5 {
+		t.Errorf("peak of the start = %v, want a fade in to %v", first, rest)
+	}
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+	if got := player.Fades(); got != DefaultFades {
+		t.Errorf("Fades() = %+v, want %+v", got, DefaultFades)
+	}
+	if err := player.SetFades(Fades{Pause: -time.Second}); err == nil {
+		t.Error("SetFades with a negative fade succeeded, want an error")
+	}
+	events, cancel := player.Subscribe()
+	defer cancel()
+
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	if err := player.FadeTo(-2, -time.Second); err == nil {
+		t.Error("FadeTo with a negative duration succeeded, want an error")
+	}
+	if err := player.FadeTo(-2, 200*time.Millisecond); err != nil {
+		t.Fatalf("FadeTo failed: %v", err)
+	}
+	if v := player.Info().Volume; v != -2 {
+		t.Errorf("Info().Volume = %v, want -2", v)
+	}
+	e := nextEvent(t, events)
+	for e.Type != EventVolumeChanged {
+		e = nextEvent(t, events)
+	}
+	if e.Volume != -2 {
+		t.Errorf("event volume = %v, want -2", e.Volume)
+	}
+}
+
+func TestPlayer_SeekPreservePitch(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "sine.wav")
+	writeTestWAV(t, fileName, 44100, 5*time.Second)
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+	player.SetPreservePitch(true)
+	if err := player.SetSpeed(1.5); err != nil {
+		t.Fatalf("SetSpeed failed: %v", err)
+	}
+
+	// An effect after the time-stretch records the largest step between two samples.
+	var jump float64
+	last, started := 0.0, false
+	err := player.AddEffect("probe", func(rate beep.SampleRate, s beep.Streamer) beep.Streamer {
+		return beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+			n, ok = s.Stream(samples)
+			for _, sample := range samples[:n] {
+				if started {
+					jump = max(jump, math.Abs(sample[0]-last))
+				}
+				last, started = sample[0], true
+			}
+			return n, ok
+		})
+	})
+	if err != nil {
+		t.Fatalf("AddEffect failed: %v", err)
+	}
+
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if err := player.ToPosition(2*time.Second + 3*time.Millisecond); err != nil {
+		t.Fatalf("ToPosition failed: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if err := player.ToPositionByOffset(-time.Second + 7*time.Millisecond); err != nil {
+		t.Fatalf("ToPositionByOffset failed: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	// A sine of 440 Hz at this level moves about 0.03 between two samples.
+	output.Lock()
+	defer output.Unlock()
+	if jump > 0.06 {
+		t.Errorf("largest step between samples = %v, want a seek without a click", jump)
+	}
+}
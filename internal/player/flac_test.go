@@ -0,0 +1,132 @@
+package player
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+// writeTestFLAC writes a stereo ramp of length d to a FLAC file, sample i of
+// the left channel is i and the right channel is -i, wrapped to the bit depth.
+// blocks are written as extra metadata.
+func writeTestFLAC(t *testing.T, filename string, sampleRate uint32, bps uint8, d time.Duration, blocks ...*meta.Block) {
+	t.Helper()
+
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	const blockSize = 4096
+	total := int(int64(sampleRate) * int64(d) / int64(time.Second))
+	info := &meta.StreamInfo{
+		BlockSizeMin:  blockSize,
+		BlockSizeMax:  blockSize,
+		SampleRate:    sampleRate,
+		NChannels:     2,
+		BitsPerSample: bps,
+		NSamples:      uint64(total),
+	}
+	enc, err := flac.NewEncoder(f, info, blocks...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limit := int32(1) << (bps - 1)
+	for start := 0; start < total; start += blockSize {
+		n := min(blockSize, total-start)
+		left := make([]int32, n)
+		right := make([]int32, n)
+		for i := range left {
+			left[i] = int32(start+i) % limit
+			right[i] = -left[i]
+		}
+
+		fr := &frame.Frame{
+			Header: frame.Header{
+				HasFixedBlockSize: true,
+				BlockSize:         uint16(n),
+				SampleRate:        sampleRate,
+				Channels:          frame.ChannelsLR,
+				BitsPerSample:     bps,
+				Num:               uint64(start / blockSize),
+			},
+			Subframes: []*frame.Subframe{
+				{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: left, NSamples: n},
+				{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: right, NSamples: n},
+			},
+		}
+		if err := enc.WriteFrame(fr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeFLAC_24bitSeek(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "ramp.flac")
+	writeTestFLAC(t, fileName, 96000, 24, time.Second)
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamer, format, err := decodeFLAC(f)
+	if err != nil {
+		t.Fatalf("decodeFLAC(%s) failed: %v", fileName, err)
+	}
+	defer streamer.Close()
+
+	if format.SampleRate != 96000 || format.Precision != 3 {
+		t.Errorf("format = %+v, want 96000 Hz and 3 bytes precision", format)
+	}
+	if streamer.Len() != 96000 {
+		t.Errorf("Len() = %d, want %d", streamer.Len(), 96000)
+	}
+
+	const pos = 50000 // in the middle of a frame
+	if err := streamer.Seek(pos); err != nil {
+		t.Fatalf("Seek(%d) failed: %v", pos, err)
+	}
+	if streamer.Position() != pos {
+		t.Errorf("Position() = %d, want %d", streamer.Position(), pos)
+	}
+
+	samples := make([][2]float64, 1)
+	if n, ok := streamer.Stream(samples); n != 1 || !ok {
+		t.Fatalf("Stream() = %d, %v, want 1, true", n, ok)
+	}
+	want := float64(pos) / (1 << 23)
+	if math.Abs(samples[0][0]-want) > 1e-12 || math.Abs(samples[0][1]+want) > 1e-12 {
+		t.Errorf("sample after seek = %v, want [%v %v]", samples[0], want, -want)
+	}
+}
+
+func TestPlayer_PlayFLAC(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "ramp.flac")
+	writeTestFLAC(t, fileName, 48000, 16, time.Second)
+
+	output := NewNullOutput(false)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	completed := make(chan struct{})
+	player.SetOnComplete(func() { close(completed) })
+
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	if info := player.Info(); info.Length != time.Second {
+		t.Errorf("Info().Length = %v, want %v", info.Length, time.Second)
+	}
+	waitFor(t, completed, time.Second)
+}
@@ -0,0 +1,248 @@
+package player
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/mp3"
+	"github.com/faiface/beep/wav"
+)
+
+// sniffLen is the number of bytes read after any ID3v2 tag to detect the format.
+const sniffLen = 512
+
+// ErrUnsupportedFormat is matched by every UnsupportedFormatError through errors.Is.
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// UnsupportedFormatError is returned when no registered format matches a file.
+type UnsupportedFormatError struct {
+	Filename string
+	// Detected names what the file looks like, e.g. "aiff" or "ogg/speex",
+	// empty when nothing was recognized.
+	Detected string
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	if e.Detected == "" {
+		return fmt.Sprintf("%s: %v (unknown)", e.Filename, ErrUnsupportedFormat)
+	}
+	return fmt.Sprintf("%s: %v (%s)", e.Filename, ErrUnsupportedFormat, e.Detected)
+}
+
+func (e *UnsupportedFormatError) Is(target error) bool {
+	return target == ErrUnsupportedFormat
+}
+
+// FileFormat is an audio file format the Player can decode.
+type FileFormat struct {
+	// Name identifies the format, e.g. "mp3".
+	Name string
+	// Exts lists the lower case file extensions of the format, with the dot.
+	Exts []string
+	// Match reports whether header belongs to the format. header holds the
+	// first bytes of the file after any ID3v2 tag and may be short.
+	Match func(header []byte) bool
+	// Decode decodes the file from its start. The file is closed by the
+	// streamer, or by the caller if Decode fails.
+	Decode func(rsc io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error)
+	// Tags reads the tags the format itself defines, like Vorbis comments,
+	// from r positioned after any ID3v2 tag. It is optional.
+	Tags func(r io.ReadSeeker) (Tags, error)
+}
+
+var (
+	formatsMx sync.RWMutex
+	formats   []FileFormat
+)
+
+// RegisterFormat adds a format the Player can decode.
+// Formats are matched in the order they were registered.
+func RegisterFormat(f FileFormat) {
+	formatsMx.Lock()
+	defer formatsMx.Unlock()
+	formats = append(formats, f)
+}
+
+func init() {
+	RegisterFormat(FileFormat{Name: "mp3", Exts: []string{".mp3"}, Match: isMP3, Decode: decodeMP3})
+	RegisterFormat(FileFormat{Name: "wav", Exts: []string{".wav"}, Match: isWAV, Decode: decodeWAV})
+}
+
+// IsSupported reports whether the Player can play filename, judged by its extension.
+// Files with other names may still play, Play detects the format from the
+// content, see Detect.
+func IsSupported(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	formatsMx.RLock()
+	defer formatsMx.RUnlock()
+	for _, f := range formats {
+		if slices.Contains(f.Exts, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Detect returns the name of the format of the file filename, detected from
+// its content like Play does, whatever its extension. A file that matches no
+// registered format fails with an *UnsupportedFormatError.
+func Detect(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fileFormat, detected, err := sniffFormat(f)
+	if err != nil {
+		return "", err
+	}
+	if fileFormat == nil {
+		return "", &UnsupportedFormatError{Filename: filename, Detected: detected}
+	}
+	return fileFormat.Name, nil
+}
+
+// sniffFormat detects the format of r and seeks r back to its start.
+// When no format matches, it returns a description of what was found instead.
+func sniffFormat(r io.ReadSeeker) (*FileFormat, string, error) {
+	offset, err := id3v2Size(r)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, "", err
+	}
+	header = header[:n]
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+
+	formatsMx.RLock()
+	defer formatsMx.RUnlock()
+	for i := range formats {
+		if formats[i].Match(header) {
+			f := formats[i]
+			return &f, f.Name, nil
+		}
+	}
+	return nil, describeFormat(header), nil
+}
+
+// id3v2Size returns the size of the ID3v2 tag at the start of r, 0 if there is none.
+func id3v2Size(r io.Reader) (int64, error) {
+	var header [10]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if string(header[:3]) != "ID3" {
+		return 0, nil
+	}
+
+	// The size is a 28 bit syncsafe integer without the header and the footer.
+	size := int64(syncsafe(header[6:10])) + 10
+	if header[5]&0x10 != 0 {
+		size += 10
+	}
+	return size, nil
+}
+
+// mpegLayer returns the layer (1 to 3) of the MPEG audio frame header at the
+// start of header, skipping zero padding, or 0 if there is none.
+func mpegLayer(header []byte) int {
+	header = bytes.TrimLeft(header, "\x00")
+	if len(header) < 4 || header[0] != 0xff || header[1]&0xe0 != 0xe0 {
+		return 0
+	}
+
+	version := header[1] >> 3 & 0x03
+	layer := header[1] >> 1 & 0x03
+	bitrate := header[2] >> 4
+	sampleRate := header[2] >> 2 & 0x03
+	if version == 1 || layer == 0 || bitrate == 0x0f || sampleRate == 0x03 {
+		return 0
+	}
+	return 4 - int(layer)
+}
+
+func isMP3(header []byte) bool {
+	return mpegLayer(header) == 3
+}
+
+func isWAV(header []byte) bool {
+	return len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WAVE"
+}
+
+func decodeMP3(rsc io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+	return mp3.Decode(rsc)
+}
+
+func decodeWAV(rsc io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+	return wav.Decode(rsc)
+}
+
+// describeFormat names common formats the Player has no decoder for.
+func describeFormat(header []byte) string {
+	hasPrefix := func(s string) bool { return bytes.HasPrefix(header, []byte(s)) }
+
+	switch {
+	case hasPrefix("OggS"):
+		if packet := oggFirstPacket(header); len(packet) > 0 {
+			switch {
+			case bytes.HasPrefix(packet, []byte("\x7fFLAC")):
+				return "ogg/flac"
+			case bytes.HasPrefix(packet, []byte("Speex   ")):
+				return "ogg/speex"
+			}
+		}
+		return "ogg"
+	case hasPrefix("RIFF"):
+		if len(header) >= 12 {
+			return "riff/" + strings.TrimSpace(strings.ToLower(string(header[8:12])))
+		}
+		return "riff"
+	case hasPrefix("FORM") && len(header) >= 12 && (string(header[8:12]) == "AIFF" || string(header[8:12]) == "AIFC"):
+		return "aiff"
+	case len(header) >= 8 && string(header[4:8]) == "ftyp":
+		return "mp4"
+	case hasPrefix("\x30\x26\xb2\x75\x8e\x66\xcf\x11"):
+		return "asf"
+	case hasPrefix("MAC "):
+		return "ape"
+	case hasPrefix("wvpk"):
+		return "wavpack"
+	case hasPrefix("caff"):
+		return "caf"
+	case hasPrefix("#!AMR"):
+		return "amr"
+	case hasPrefix("\x1a\x45\xdf\xa3"):
+		return "matroska"
+	}
+
+	switch mpegLayer(header) {
+	case 1:
+		return "mp1"
+	case 2:
+		return "mp2"
+	}
+	return ""
+}
@@ -0,0 +1,172 @@
+package player
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSniffFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	wavName := filepath.Join(dir, "sine.wav")
+	writeTestWAV(t, wavName, 44100, 100*time.Millisecond)
+	flacName := filepath.Join(dir, "ramp.flac")
+	writeTestFLAC(t, flacName, 44100, 16, 100*time.Millisecond)
+	opusName := filepath.Join(dir, "test.opus")
+	writeTestOpus(t, opusName, 312, 10)
+
+	flacData, err := os.ReadFile(flacName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An ID3v2 tag of 20 bytes, padding included, in front of the FLAC stream.
+	id3Name := filepath.Join(dir, "tagged.flac")
+	tagged := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x14"), make([]byte, 20)...)
+	if err := os.WriteFile(id3Name, append(tagged, flacData...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mp3Name := filepath.Join(dir, "frame.mp3")
+	if err := os.WriteFile(mp3Name, append([]byte{0xff, 0xfb, 0x90, 0x64}, make([]byte, 100)...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	aiffName := filepath.Join(dir, "song.aiff")
+	if err := os.WriteFile(aiffName, []byte("FORM\x00\x00\x00\x04AIFF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filename string
+		format   string
+		detected string
+	}{
+		{wavName, "wav", "wav"},
+		{flacName, "flac", "flac"},
+		{opusName, "opus", "opus"},
+		{id3Name, "flac", "flac"},
+		{mp3Name, "mp3", "mp3"},
+		{aiffName, "", "aiff"},
+	}
+	for _, tt := range tests {
+		f, err := os.Open(tt.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		format, detected, err := sniffFormat(f)
+		if err != nil {
+			t.Errorf("sniffFormat(%s) failed: %v", tt.filename, err)
+		}
+		name := ""
+		if format != nil {
+			name = format.Name
+		}
+		if name != tt.format || detected != tt.detected {
+			t.Errorf("sniffFormat(%s) = %q, %q, want %q, %q", tt.filename, name, detected, tt.format, tt.detected)
+		}
+
+		// The file is back at its start for the decoder.
+		header := make([]byte, 4)
+		if _, err := f.Read(header); err != nil || !bytes.Equal(header, mustRead(t, tt.filename)[:4]) {
+			t.Errorf("sniffFormat(%s) did not seek back to the start", tt.filename)
+		}
+		f.Close()
+	}
+}
+
+func mustRead(t *testing.T, filename string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestPlayer_PlayMisnamed(t *testing.T) {
+	dir := t.TempDir()
+	flacName := filepath.Join(dir, "ramp.flac")
+	writeTestFLAC(t, flacName, 44100, 16, time.Second)
+
+	// A FLAC file without an extension and one with the wrong extension, upper case.
+	for _, name := range []string{"download", "RAMP.MP3"} {
+		fileName := filepath.Join(dir, name)
+		if err := os.WriteFile(fileName, mustRead(t, flacName), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		output := NewNullOutput(false)
+		player := NewPlayerWithOutput(output)
+		if err := player.Play(fileName); err != nil {
+			t.Errorf("Player.Play(%s) failed: %v", fileName, err)
+		} else if info := player.Info(); info.Length != time.Second {
+			t.Errorf("Info().Length = %v, want %v", info.Length, time.Second)
+		}
+		player.Close()
+		output.Close()
+	}
+}
+
+func TestPlayer_PlayUnsupported(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "song.mp3")
+	if err := os.WriteFile(fileName, []byte("FORM\x00\x00\x00\x04AIFC"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := NewNullOutput(false)
+	defer output.Close()
+
+	err := NewPlayerWithOutput(output).Play(fileName)
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("Player.Play(%s) = %v, want %v", fileName, err, ErrUnsupportedFormat)
+	}
+	var formatErr *UnsupportedFormatError
+	if !errors.As(err, &formatErr) || formatErr.Detected != "aiff" {
+		t.Errorf("Player.Play(%s) = %v, want an UnsupportedFormatError detecting aiff", fileName, err)
+	}
+}
+
+func TestIsSupported(t *testing.T) {
+	for name, want := range map[string]bool{
+		"a.mp3":  true,
+		"a.MP3":  true,
+		"a.Flac": true,
+		"a.opus": true,
+		"a.oga":  true,
+		"a.aiff": false,
+		"a":      false,
+	} {
+		if got := IsSupported(name); got != want {
+			t.Errorf("IsSupported(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	flacName := filepath.Join(dir, "ramp.flac")
+	writeTestFLAC(t, flacName, 44100, 16, time.Second)
+	misnamed := filepath.Join(dir, "download")
+	if err := os.WriteFile(misnamed, mustRead(t, flacName), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	aiffName := filepath.Join(dir, "song.mp3")
+	if err := os.WriteFile(aiffName, []byte("FORM\x00\x00\x00\x04AIFC"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{flacName, misnamed} {
+		if got, err := Detect(name); err != nil || got != "flac" {
+			t.Errorf("Detect(%s) = %q, %v, want flac", name, got, err)
+		}
+	}
+	if _, err := Detect(aiffName); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Detect(%s) = %v, want %v", aiffName, err, ErrUnsupportedFormat)
+	}
+}
@@ -0,0 +1,146 @@
+package player
+
+import (
+	"math"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+const (
+	limiterCeiling   = 0.891 // -1 dBFS, leaves room for inter-sample peaks
+	limiterLookahead = 5 * time.Millisecond
+	limiterRelease   = 100 * time.Millisecond
+	// limiterActive is the gain below which the limiter counts as limiting, about -0.1 dB.
+	limiterActive = 0.99
+)
+
+// limiter keeps the output below limiterCeiling. It delays the audio by
+// limiterLookahead, so the gain is already down when a peak comes out, and
+// recovers over limiterRelease. Whatever the gain does not catch is clipped
+// at the ceiling. A disabled limiter passes the audio through, delayed.
+//
+// It must only be touched with the output locked.
+type limiter struct {
+	Streamer beep.Streamer
+	enabled  bool
+
+	delay [][2]float64 // the last limiterLookahead samples, a ring
+	pos   int
+
+	target  float64 // gain the loudest sample in the delay needs
+	hold    int     // samples the target stays for, until its peak left the delay
+	gain    float64
+	attack  float64
+	release float64
+
+	active  bool // the gain was reduced during the last Stream
+	primed  bool // the delay was filled, so the start is not delayed by silence
+	flush   int  // delayed samples left to stream after the Streamer drained
+	drained bool
+}
+
+func newLimiter(sampleRate beep.SampleRate, enabled bool, s beep.Streamer) *limiter {
+	lookahead := sampleRate.N(limiterLookahead)
+	return &limiter{
+		Streamer: s,
+		enabled:  enabled,
+		delay:    make([][2]float64, lookahead),
+		target:   1,
+		gain:     1,
+		// The attack gets 99% of the way down within the lookahead.
+		attack:  math.Exp(math.Log(0.01) / float64(lookahead)),
+		release: math.Exp(-1 / float64(sampleRate.N(limiterRelease))),
+		flush:   lookahead,
+	}
+}
+
+func (l *limiter) Stream(samples [][2]float64) (n int, ok bool) {
+	if !l.primed {
+		l.primed = true
+		l.prime()
+	}
+	if !l.drained {
+		n = l.pull(samples)
+	}
+
+	l.active = false
+	for i := range samples[:n] {
+		samples[i] = l.process(samples[i])
+	}
+	if l.drained {
+		for ; n < len(samples) && l.flush > 0; n++ {
+			samples[n] = l.process([2]float64{})
+			l.flush--
+		}
+	}
+	return n, n > 0 || !l.drained
+}
+
+func (l *limiter) Err() error {
+	return l.Streamer.Err()
+}
+
+// prime fills the delay with the first samples of the Streamer.
+func (l *limiter) prime() {
+	first := make([][2]float64, len(l.delay))
+	n := l.pull(first)
+	for i := range first[:n] {
+		l.process(first[i])
+	}
+	// The first sample comes out next, even if the Streamer drained before the delay was full.
+	l.pos = 0
+	l.flush = n
+}
+
+// pull streams from the Streamer until samples are full or it drained. A
+// short read is not the end, only ok is.
+func (l *limiter) pull(samples [][2]float64) (n int) {
+	for n < len(samples) {
+		sn, sok := l.Streamer.Stream(samples[n:])
+		n += sn
+		if !sok {
+			l.drained = true
+			return n
+		}
+		if sn == 0 {
+			return n // Nothing for now, the Streamer may have more later.
+		}
+	}
+	return n
+}
+
+// process pushes x into the delay and returns the sample that leaves it.
+func (l *limiter) process(x [2]float64) [2]float64 {
+	need := 1.0
+	if peak := max(math.Abs(x[0]), math.Abs(x[1])); l.enabled && peak > limiterCeiling {
+		need = limiterCeiling / peak
+	}
+	if need <= l.target {
+		l.target, l.hold = need, len(l.delay)
+	} else if l.hold > 0 {
+		l.hold--
+	} else {
+		l.target = need
+	}
+
+	if l.target < l.gain {
+		l.gain = l.target + (l.gain-l.target)*l.attack
+	} else {
+		l.gain = l.target + (l.gain-l.target)*l.release
+	}
+	if l.gain < limiterActive {
+		l.active = true
+	}
+
+	y := l.delay[l.pos]
+	l.delay[l.pos] = x
+	l.pos = (l.pos + 1) % len(l.delay)
+	for ch := range y {
+		y[ch] *= l.gain
+		if l.enabled {
+			y[ch] = max(-limiterCeiling, min(y[ch], limiterCeiling))
+		}
+	}
+	return y
+}
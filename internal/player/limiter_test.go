@@ -0,0 +1,160 @@
+package player
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// peak returns the largest absolute sample.
+func peak(samples [][2]float64) float64 {
+	var p float64
+	for _, s := range samples {
+		p = max(p, math.Abs(s[0]), math.Abs(s[1]))
+	}
+	return p
+}
+
+func TestLimiter(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		// A sine at +6 dBFS, with a quiet start the limiter has to look ahead of.
+		input := make([][2]float64, 44100)
+		for i := range input {
+			v := 2 * math.Sin(2*math.Pi*440*float64(i)/44100)
+			if i < 10000 {
+				v /= 10
+			}
+			input[i] = [2]float64{v, -v}
+		}
+		want := append([][2]float64(nil), input...)
+
+		l := newLimiter(44100, enabled, beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+			n = copy(samples, input)
+			input = input[n:]
+			return n, n > 0
+		}))
+
+		var got [][2]float64
+		var active bool
+		buf := make([][2]float64, 512)
+		for {
+			n, ok := l.Stream(buf)
+			got = append(got, buf[:n]...)
+			active = active || l.active
+			if !ok {
+				break
+			}
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("enabled %v: streamed %d samples, want %d", enabled, len(got), len(want))
+		}
+		if !enabled {
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("disabled: sample %d = %v, want %v", i, got[i], want[i])
+				}
+			}
+			if active {
+				t.Error("disabled: limiter was active")
+			}
+			continue
+		}
+
+		if p := peak(got); p > limiterCeiling {
+			t.Errorf("peak = %v, want at most %v", p, limiterCeiling)
+		}
+		if p := peak(got[20000:]); p < 0.8*limiterCeiling {
+			t.Errorf("peak of the loud part = %v, want close to %v", p, limiterCeiling)
+		}
+		// The quiet start is left alone until the lookahead reaches the loud part.
+		lookahead := DefaultSampleRate.N(limiterLookahead)
+		for i := range got[:10000-lookahead] {
+			if math.Abs(got[i][0]-want[i][0]) > 1e-9 {
+				t.Fatalf("sample %d = %v, want %v", i, got[i], want[i])
+			}
+		}
+		if !active {
+			t.Error("limiter was not active")
+		}
+	}
+}
+
+func TestLimiter_ShortReads(t *testing.T) {
+	// A source that streams at most 100 samples at a time, like a decoder at
+	// the end of a frame, is not drained until it says so.
+	input := make([][2]float64, 5000)
+	for i := range input {
+		input[i] = [2]float64{float64(i+1) / 10000, 0}
+	}
+	rest := input
+	l := newLimiter(44100, false, beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		n = copy(samples[:min(len(samples), 100)], rest)
+		rest = rest[n:]
+		return n, n > 0
+	}))
+
+	var got [][2]float64
+	buf := make([][2]float64, 512)
+	for {
+		n, ok := l.Stream(buf)
+		got = append(got, buf[:n]...)
+		if !ok {
+			break
+		}
+		if n != len(buf) && len(got) < len(input) {
+			t.Fatalf("streamed %d of %d samples before the end", n, len(buf))
+		}
+	}
+	if len(got) != len(input) {
+		t.Fatalf("streamed %d samples, want %d", len(got), len(input))
+	}
+	for i := range got {
+		if got[i] != input[i] {
+			t.Fatalf("sample %d = %v, want %v", i, got[i], input[i])
+		}
+	}
+}
+
+func TestPlayer_Limiter(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "sine.wav")
+	writeTestWAV(t, fileName, 44100, time.Second)
+
+	// The WAV decoder reads 16-bit samples at half their level, so full scale reads as 0.5.
+	const fullScale = 0.5
+
+	// A volume of 3 boosts the sine 8 times, far above full scale.
+	samples := renderWAV(t, fileName, func(p *Player) { p.SetVolume(3) })
+	if p := peak(samples) / fullScale; p > limiterCeiling+1e-3 || p < 0.8*limiterCeiling {
+		t.Errorf("peak = %v, want close to %v", p, limiterCeiling)
+	}
+
+	samples = renderWAV(t, fileName, func(p *Player) {
+		p.SetVolume(3)
+		p.SetLimiter(false)
+	})
+	if p := peak(samples) / fullScale; p < 0.99 {
+		t.Errorf("peak without the limiter = %v, want clipping", p)
+	}
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+	if !player.Limiter() {
+		t.Error("Limiter() = false, want the limiter on by default")
+	}
+
+	player.SetVolume(3)
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if !player.Info().Limiting {
+		t.Error("Info().Limiting = false, want true")
+	}
+}
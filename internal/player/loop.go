@@ -0,0 +1,60 @@
+package player
+
+import "github.com/faiface/beep"
+
+// abLoop sits between a decoder and its resampler. Once the decoder reaches
+// b it is moved back to a, within the same Stream call, so the loop has no gap.
+// The points are in decoder samples, with b == 0 the loop is off.
+//
+// It must only be touched with the output locked.
+type abLoop struct {
+	s    beep.StreamSeeker
+	a, b int
+	hasA bool // a was marked, the loop runs once b is set
+	err  error
+}
+
+// looping reports whether the loop is running.
+func (l *abLoop) looping() bool {
+	return l.b > 0
+}
+
+func (l *abLoop) clear() {
+	l.a, l.b, l.hasA = 0, 0, false
+}
+
+func (l *abLoop) Stream(samples [][2]float64) (n int, ok bool) {
+	if l.err != nil {
+		return 0, false
+	}
+
+	for n < len(samples) {
+		chunk := samples[n:]
+		if l.looping() {
+			pos := l.s.Position()
+			if pos >= l.b {
+				if err := l.s.Seek(l.a); err != nil {
+					l.err = err
+					return n, n > 0
+				}
+				pos = l.a
+			}
+			chunk = chunk[:min(len(chunk), l.b-pos)]
+		}
+
+		sn, sok := l.s.Stream(chunk)
+		n += sn
+		if !sok || sn == 0 {
+			return n, sok || n > 0
+		}
+	}
+	return n, true
+}
+
+// Err returns the error of a failed jump back to a, or the error of the decoder.
+func (l *abLoop) Err() error {
+	if l.err != nil {
+		return l.err
+	}
+	return l.s.Err()
+}
@@ -0,0 +1,142 @@
+package player
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+func TestABLoop(t *testing.T) {
+	// A ramp, every sample holds its own index in thousandths.
+	ramp := make([][2]float64, 1000)
+	for i := range ramp {
+		ramp[i] = [2]float64{float64(i) / 1000, float64(i) / 1000}
+	}
+	buffer := beep.NewBuffer(beep.Format{SampleRate: 44100, NumChannels: 2, Precision: 2})
+	buffer.Append(beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		n = copy(samples, ramp)
+		ramp = ramp[n:]
+		return n, n > 0
+	}))
+	s := buffer.Streamer(0, buffer.Len())
+
+	l := &abLoop{s: s, a: 100, b: 250, hasA: true}
+	var got []int
+	buf := make([][2]float64, 64)
+	for len(got) < 1000 {
+		n, ok := l.Stream(buf)
+		if !ok {
+			t.Fatal("the loop ended")
+		}
+		for _, sample := range buf[:n] {
+			got = append(got, int(math.Round(sample[0]*1000)))
+		}
+	}
+
+	want := 0
+	for i, v := range got {
+		if v != want {
+			t.Fatalf("sample %d = %d, want %d", i, v, want)
+		}
+		if want++; want == 250 {
+			want = 100
+		}
+	}
+
+	// After b it jumps back to a.
+	if err := s.Seek(500); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := l.Stream(buf[:1]); n != 1 || math.Round(buf[0][0]*1000) != 100 {
+		t.Errorf("sample after b = %v, want 0.1", buf[0][0])
+	}
+
+	// Cleared, it plays to the end.
+	l.clear()
+	total := 1
+	for {
+		n, ok := l.Stream(buf)
+		total += n
+		if !ok {
+			break
+		}
+	}
+	if want := 1 + 1000 - 101; total != want {
+		t.Errorf("streamed %d samples after clear, want %d", total, want)
+	}
+}
+
+func TestPlayer_Loop(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "sine.wav")
+	writeTestWAV(t, fileName, 44100, time.Second)
+	nextName := filepath.Join(dir, "next.wav")
+	writeTestWAV(t, nextName, 44100, time.Second)
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+	advanced := make(chan struct{})
+	player.SetOnAdvance(func(string) { close(advanced) })
+
+	if err := player.SetLoop(0, time.Second); err == nil {
+		t.Error("SetLoop succeeded with nothing playing, want an error")
+	}
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	if err := player.Queue(nextName); err != nil {
+		t.Fatalf("Player.Queue(%s) failed: %v", nextName, err)
+	}
+
+	for _, tt := range [][2]time.Duration{{300 * time.Millisecond, 100 * time.Millisecond}, {0, 2 * time.Second}} {
+		if err := player.SetLoop(tt[0], tt[1]); err == nil {
+			t.Errorf("SetLoop(%v, %v) succeeded, want an error", tt[0], tt[1])
+		}
+	}
+	if err := player.SetLoop(100*time.Millisecond, 300*time.Millisecond); err != nil {
+		t.Fatalf("SetLoop failed: %v", err)
+	}
+
+	// Long past the end of the file, it still loops and the queued track waits.
+	time.Sleep(1500 * time.Millisecond)
+	select {
+	case <-advanced:
+		t.Fatal("moved on to the queued track while looping")
+	default:
+	}
+	info := player.Info()
+	if !info.Looping || info.LoopA != 100*time.Millisecond || info.LoopB != 300*time.Millisecond {
+		t.Errorf("Info() loop = %v, %v-%v, want a loop from 100ms to 300ms", info.Looping, info.LoopA, info.LoopB)
+	}
+	if info.Current < 100*time.Millisecond || info.Current > 300*time.Millisecond {
+		t.Errorf("Info().Current = %v, want within the loop", info.Current)
+	}
+
+	player.ClearLoop()
+	waitFor(t, advanced, 2*time.Second)
+	if info := player.Info(); info.Looping || info.HasLoopA {
+		t.Error("the queued track started with a loop")
+	}
+
+	// Marking the points at the current position.
+	time.Sleep(200 * time.Millisecond)
+	if err := player.SetLoopA(); err != nil {
+		t.Fatalf("SetLoopA failed: %v", err)
+	}
+	if info := player.Info(); !info.HasLoopA || info.Looping {
+		t.Errorf("Info() after SetLoopA = %v, %v, want A set and no loop yet", info.HasLoopA, info.Looping)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := player.SetLoopB(); err != nil {
+		t.Fatalf("SetLoopB failed: %v", err)
+	}
+	if info := player.Info(); !info.Looping || info.LoopB <= info.LoopA {
+		t.Errorf("Info() after SetLoopB = %v, %v-%v, want a loop", info.Looping, info.LoopA, info.LoopB)
+	}
+}
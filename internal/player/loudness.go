@@ -0,0 +1,338 @@
+package player
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+const (
+	// loudnessGate is the absolute gate of EBU R128, quieter blocks are ignored
+	// and a track that never gets louder counts as silent.
+	loudnessGate = -70.0
+	// replayGainReference is the loudness ReplayGain 2.0 levels tracks to.
+	replayGainReference = -18.0
+
+	truePeakFactor = 4  // oversampling of the true peak meter
+	truePeakTaps   = 12 // taps per phase of the oversampling filter
+)
+
+// Loudness is the result of an EBU R128 analysis of a track.
+type Loudness struct {
+	Integrated float64 `json:"integrated"` // integrated loudness in LUFS
+	TruePeak   float64 `json:"true_peak"`  // true peak as sample amplitude
+
+	// The album values are only set when the track was scanned as part of an album.
+	AlbumIntegrated float64 `json:"album_integrated,omitempty"`
+	AlbumTruePeak   float64 `json:"album_true_peak,omitempty"`
+	HasAlbum        bool    `json:"has_album,omitempty"`
+}
+
+// replayGain converts the loudness to the ReplayGain that levels the track to -18 LUFS.
+func (l Loudness) replayGain() ReplayGain {
+	var rg ReplayGain
+	if l.Integrated > loudnessGate {
+		rg.TrackGain, rg.TrackPeak, rg.HasTrack = replayGainReference-l.Integrated, l.TruePeak, true
+	}
+	if l.HasAlbum && l.AlbumIntegrated > loudnessGate {
+		rg.AlbumGain, rg.AlbumPeak, rg.HasAlbum = replayGainReference-l.AlbumIntegrated, l.AlbumTruePeak, true
+	}
+	return rg
+}
+
+// ScanLoudness measures the integrated loudness and the true peak of filename.
+func ScanLoudness(filename string) (Loudness, error) {
+	m, err := measureLoudness(filename)
+	if err != nil {
+		return Loudness{}, err
+	}
+	return Loudness{Integrated: integratedLoudness(m.blocks), TruePeak: m.truePeak}, nil
+}
+
+// ScanAlbum measures the tracks of an album with up to workers tracks at once,
+// along with the loudness of the whole album. errs[i] is why filenames[i]
+// could not be scanned, nil if it was. A track that failed gets no Loudness
+// and the album is measured from the tracks that did not.
+func ScanAlbum(filenames []string, workers int) (results []Loudness, errs []error) {
+	ScanAlbums([][]string{filenames}, workers, func(_ int, r []Loudness, e []error) {
+		results, errs = r, e
+	})
+	return results, errs
+}
+
+// ScanAlbums measures albums, each a list of tracks, like ScanAlbum does one,
+// with up to workers tracks at once across all of them. done is called with
+// the results of an album once all its tracks are measured, one album at a
+// time and on the goroutine of the caller.
+func ScanAlbums(albums [][]string, workers int, done func(album int, results []Loudness, errs []error)) {
+	scanGroups(albums, workers, func(album int, meters []*loudnessMeter, errs []error) {
+		done(album, albumLoudness(meters, errs), errs)
+	})
+}
+
+// ScanTracks measures filenames like ScanLoudness does one, with up to workers
+// files at once. done is called with the result of every file once it is
+// measured, one file at a time and on the goroutine of the caller.
+func ScanTracks(filenames []string, workers int, done func(i int, l Loudness, err error)) {
+	groups := make([][]string, len(filenames))
+	for i, filename := range filenames {
+		groups[i] = []string{filename}
+	}
+	scanGroups(groups, workers, func(i int, meters []*loudnessMeter, errs []error) {
+		if errs[0] != nil {
+			done(i, Loudness{}, errs[0])
+			return
+		}
+		done(i, Loudness{Integrated: integratedLoudness(meters[0].blocks), TruePeak: meters[0].truePeak}, nil)
+	})
+}
+
+// scanGroups measures the files of groups with up to workers files at once
+// and calls done for every group once all its files are measured.
+func scanGroups(groups [][]string, workers int, done func(group int, meters []*loudnessMeter, errs []error)) {
+	type job struct{ group, i int }
+	type result struct {
+		job
+		meter *loudnessMeter
+		err   error
+	}
+
+	jobs := make(chan job)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				m, err := measureLoudness(groups[j.group][j.i])
+				results <- result{j, m, err}
+			}
+		}()
+	}
+	go func() {
+		for group, filenames := range groups {
+			for i := range filenames {
+				jobs <- job{group, i}
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	meters := make([][]*loudnessMeter, len(groups))
+	errs := make([][]error, len(groups))
+	left := make([]int, len(groups))
+	for group, filenames := range groups {
+		meters[group] = make([]*loudnessMeter, len(filenames))
+		errs[group] = make([]error, len(filenames))
+		left[group] = len(filenames)
+		if len(filenames) == 0 {
+			done(group, nil, nil)
+		}
+	}
+	for r := range results {
+		meters[r.group][r.i], errs[r.group][r.i] = r.meter, r.err
+		if left[r.group]--; left[r.group] == 0 {
+			done(r.group, meters[r.group], errs[r.group])
+			meters[r.group] = nil // Let the blocks go
+		}
+	}
+}
+
+// albumLoudness returns the loudness of the tracks of an album measured by
+// meters, along with the loudness of the album. Tracks with an error get no
+// Loudness and are left out of the album.
+func albumLoudness(meters []*loudnessMeter, errs []error) []Loudness {
+	var blocks []float64
+	var peak float64
+	for i, m := range meters {
+		if errs[i] == nil {
+			blocks = append(blocks, m.blocks...)
+			peak = max(peak, m.truePeak)
+		}
+	}
+
+	album := integratedLoudness(blocks)
+	results := make([]Loudness, len(meters))
+	for i, m := range meters {
+		if errs[i] != nil {
+			continue
+		}
+		results[i] = Loudness{
+			Integrated:      integratedLoudness(m.blocks),
+			TruePeak:        m.truePeak,
+			AlbumIntegrated: album,
+			AlbumTruePeak:   peak,
+			HasAlbum:        true,
+		}
+	}
+	return results
+}
+
+// measureLoudness decodes filename and runs it through a loudnessMeter.
+func measureLoudness(filename string) (*loudnessMeter, error) {
+	streamer, format, err := loadStreamer(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer streamer.Close()
+
+	m := newLoudnessMeter(format.SampleRate, format.NumChannels)
+	buf := make([][2]float64, 4096)
+	for {
+		n, ok := streamer.Stream(buf)
+		m.write(buf[:n])
+		if !ok {
+			break
+		}
+	}
+	if err := streamer.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// integratedLoudness gates the mean square energies of 400 ms blocks as in
+// ITU-R BS.1770 and returns the loudness in LUFS, loudnessGate for silence.
+func integratedLoudness(blocks []float64) float64 {
+	gated := func(threshold float64) (float64, int) {
+		var sum float64
+		var n int
+		for _, e := range blocks {
+			if e > threshold {
+				sum += e
+				n++
+			}
+		}
+		return sum, n
+	}
+
+	sum, n := gated(energy(loudnessGate))
+	if n == 0 {
+		return loudnessGate
+	}
+	// The relative gate is 10 LU below the loudness of the absolutely gated blocks.
+	sum, n = gated(max(sum/float64(n)/10, energy(loudnessGate)))
+	if n == 0 {
+		return loudnessGate
+	}
+	return max(-0.691+10*math.Log10(sum/float64(n)), loudnessGate)
+}
+
+// energy converts loudness in LUFS to the mean square energy of a block.
+func energy(lufs float64) float64 {
+	return math.Pow(10, (lufs+0.691)/10)
+}
+
+// loudnessMeter measures a stream at its own sample rate.
+// Mono streams are measured on the left channel only, as their right channel is a copy.
+type loudnessMeter struct {
+	channels int
+	shelf    biquad // K-weighting, stage 1
+	highpass biquad // K-weighting, stage 2
+
+	step    int       // samples per 100 ms
+	steps   []float64 // sums of squares of the last 4 steps, a 400 ms block
+	sum     float64   // sum of squares of the step being measured
+	samples int       // samples in the step being measured
+	blocks  []float64 // mean square energy of every block, overlapping by 75%
+
+	taps     [truePeakFactor][truePeakTaps]float64
+	history  [2][truePeakTaps]float64
+	truePeak float64
+}
+
+func newLoudnessMeter(sampleRate beep.SampleRate, channels int) *loudnessMeter {
+	m := &loudnessMeter{
+		channels: min(channels, 2),
+		step:     sampleRate.N(100 * time.Millisecond),
+	}
+	fs := float64(sampleRate)
+
+	// The filters of ITU-R BS.1770 at any sample rate, as derived for libebur128.
+	k := math.Tan(math.Pi * 1681.974450955533 / fs)
+	q := 0.7071752369554196
+	vh := math.Pow(10, 3.999843853973347/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	m.shelf = biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	k = math.Tan(math.Pi * 38.13547087602444 / fs)
+	q = 0.5003270373238773
+	a0 = 1 + k/q + k*k
+	m.highpass = biquad{
+		b0: 1, b1: -2, b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	// A windowed sinc that interpolates truePeakFactor-1 samples between two samples.
+	length := truePeakFactor * truePeakTaps
+	for i := range length {
+		x := float64(i) - float64(length-1)/2
+		sinc := 1.0
+		if x != 0 {
+			sinc = math.Sin(math.Pi*x/truePeakFactor) / (math.Pi * x / truePeakFactor)
+		}
+		window := 0.5 - 0.5*math.Cos(2*math.Pi*(float64(i)+0.5)/float64(length))
+		m.taps[i%truePeakFactor][i/truePeakFactor] = sinc * window
+	}
+	return m
+}
+
+func (m *loudnessMeter) write(samples [][2]float64) {
+	for _, s := range samples {
+		for ch := 0; ch < m.channels; ch++ {
+			x := m.highpass.process(m.shelf.process(s[ch], ch), ch)
+			m.sum += x * x
+			m.peak(s[ch], ch)
+		}
+
+		m.samples++
+		if m.samples == m.step {
+			m.endStep()
+		}
+	}
+}
+
+// endStep closes a 100 ms step, every step completes a block with the three before it.
+func (m *loudnessMeter) endStep() {
+	m.steps = append(m.steps, m.sum)
+	if len(m.steps) > 4 {
+		m.steps = m.steps[1:]
+	}
+	if len(m.steps) == 4 {
+		var sum float64
+		for _, s := range m.steps {
+			sum += s
+		}
+		m.blocks = append(m.blocks, sum/float64(4*m.step))
+	}
+	m.sum, m.samples = 0, 0
+}
+
+// peak feeds x to the oversampling filter of ch and tracks the true peak.
+func (m *loudnessMeter) peak(x float64, ch int) {
+	h := &m.history[ch]
+	copy(h[1:], h[:truePeakTaps-1])
+	h[0] = x
+
+	m.truePeak = max(m.truePeak, math.Abs(x))
+	for phase := range m.taps {
+		var y float64
+		for i, tap := range m.taps[phase] {
+			y += tap * h[i]
+		}
+		m.truePeak = max(m.truePeak, math.Abs(y))
+	}
+}
@@ -0,0 +1,115 @@
+package player
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LoudnessCache keeps loudness scan results in a JSON file, keyed by the
+// absolute path of a file. A result only counts while the file keeps its
+// modification time and size. It is safe for concurrent use.
+type LoudnessCache struct {
+	mx       sync.Mutex
+	filename string
+	entries  map[string]loudnessEntry
+}
+
+type loudnessEntry struct {
+	ModTime  time.Time `json:"mtime"`
+	Size     int64     `json:"size"`
+	Loudness Loudness  `json:"loudness"`
+}
+
+// DefaultLoudnessCacheFile returns where the loudness cache is kept by default,
+// in the user cache directory.
+func DefaultLoudnessCacheFile() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "music_player", "loudness.json"), nil
+}
+
+// LoadLoudnessCache reads the cache from filename, a file that does not exist yet holds no results.
+func LoadLoudnessCache(filename string) (*LoudnessCache, error) {
+	c := &LoudnessCache{filename: filename, entries: map[string]loudnessEntry{}}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Get returns the result for filename if the file did not change since it was scanned.
+func (c *LoudnessCache) Get(filename string) (Loudness, bool) {
+	key, info, err := cacheKey(filename)
+	if err != nil {
+		return Loudness{}, false
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !entry.ModTime.Equal(info.ModTime()) || entry.Size != info.Size() {
+		return Loudness{}, false
+	}
+	return entry.Loudness, true
+}
+
+// Put stores the result for filename, Save writes it to the file.
+func (c *LoudnessCache) Put(filename string, l Loudness) error {
+	key, info, err := cacheKey(filename)
+	if err != nil {
+		return err
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.entries[key] = loudnessEntry{ModTime: info.ModTime(), Size: info.Size(), Loudness: l}
+	return nil
+}
+
+// Save writes the cache, through a temporary file so a failed write keeps the old one.
+func (c *LoudnessCache) Save() error {
+	c.mx.Lock()
+	data, err := json.Marshal(c.entries)
+	c.mx.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.filename), 0o755); err != nil {
+		return err
+	}
+	tmp := c.filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.filename)
+}
+
+func cacheKey(filename string) (string, os.FileInfo, error) {
+	key, err := filepath.Abs(filename)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := os.Stat(key)
+	if err != nil {
+		return "", nil, err
+	}
+	return key, info, nil
+}
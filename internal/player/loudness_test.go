@@ -0,0 +1,273 @@
+package player
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+// writeSineFLAC writes a 24-bit sine of freq Hz with a peak of dBFS to a FLAC file.
+// The WAV decoder reads 16-bit files at half their level, so loudness is measured on FLAC.
+func writeSineFLAC(t *testing.T, filename string, sampleRate uint32, channels uint8, freq, dBFS, phase float64, d time.Duration) {
+	t.Helper()
+
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	const blockSize = 4096
+	total := int(int64(sampleRate) * int64(d) / int64(time.Second))
+	info := &meta.StreamInfo{
+		BlockSizeMin:  blockSize,
+		BlockSizeMax:  blockSize,
+		SampleRate:    sampleRate,
+		NChannels:     channels,
+		BitsPerSample: 24,
+		NSamples:      uint64(total),
+	}
+	enc, err := flac.NewEncoder(f, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	amplitude := math.Pow(10, dBFS/20) * (1 << 23)
+	assignment := frame.ChannelsLR
+	if channels == 1 {
+		assignment = frame.ChannelsMono
+	}
+	for start := 0; start < total; start += blockSize {
+		n := min(blockSize, total-start)
+		samples := make([]int32, n)
+		for i := range samples {
+			samples[i] = int32(math.Round(amplitude * math.Sin(2*math.Pi*freq*float64(start+i)/float64(sampleRate)+phase)))
+		}
+
+		fr := &frame.Frame{
+			Header: frame.Header{
+				HasFixedBlockSize: true,
+				BlockSize:         uint16(n),
+				SampleRate:        sampleRate,
+				Channels:          assignment,
+				BitsPerSample:     24,
+				Num:               uint64(start / blockSize),
+			},
+		}
+		for range channels {
+			fr.Subframes = append(fr.Subframes, &frame.Subframe{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: samples, NSamples: n})
+		}
+		if err := enc.WriteFrame(fr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanLoudness(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		channels uint8
+		freq     float64
+		dBFS     float64
+		phase    float64
+		lufs     float64
+		truePeak float64
+	}{
+		// EBU Tech 3341, test 1.
+		{"stereo", 2, 1000, -23, 0, -23, math.Pow(10, -23.0/20)},
+		{"mono", 1, 1000, -23, 0, -26, math.Pow(10, -23.0/20)},
+		// The samples land at ±0.35, the peak between them.
+		{"inter-sample peak", 2, 12000, -6, math.Pi / 4, math.NaN(), math.Pow(10, -6.0/20)},
+	}
+	for _, tt := range tests {
+		fileName := filepath.Join(dir, tt.name+".flac")
+		writeSineFLAC(t, fileName, 48000, tt.channels, tt.freq, tt.dBFS, tt.phase, 5*time.Second)
+
+		l, err := ScanLoudness(fileName)
+		if err != nil {
+			t.Fatalf("ScanLoudness(%s) failed: %v", fileName, err)
+		}
+		if !math.IsNaN(tt.lufs) && math.Abs(l.Integrated-tt.lufs) > 0.1 {
+			t.Errorf("%s: integrated loudness = %.2f LUFS, want %.2f LUFS", tt.name, l.Integrated, tt.lufs)
+		}
+		if math.Abs(l.TruePeak-tt.truePeak) > 0.01 {
+			t.Errorf("%s: true peak = %.4f, want %.4f", tt.name, l.TruePeak, tt.truePeak)
+		}
+	}
+}
+
+func TestScanAlbum(t *testing.T) {
+	dir := t.TempDir()
+	quiet := filepath.Join(dir, "quiet.flac")
+	loud := filepath.Join(dir, "loud.flac")
+	writeSineFLAC(t, quiet, 44100, 2, 1000, -23, 0, 3*time.Second)
+	writeSineFLAC(t, loud, 44100, 2, 1000, -13, 0, 3*time.Second)
+
+	missing := filepath.Join(dir, "missing.flac")
+
+	results, errs := ScanAlbum([]string{quiet, loud}, 2)
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("ScanAlbum of track %d failed: %v", i, err)
+		}
+	}
+
+	// Both tracks pass the relative gate, so the album is their mean energy.
+	album := 10 * math.Log10((math.Pow(10, -2.3)+math.Pow(10, -1.3))/2)
+	for i, want := range []float64{-23, -13} {
+		if math.Abs(results[i].Integrated-want) > 0.1 {
+			t.Errorf("track %d: integrated loudness = %.2f LUFS, want %.2f LUFS", i, results[i].Integrated, want)
+		}
+		if !results[i].HasAlbum || math.Abs(results[i].AlbumIntegrated-album) > 0.1 {
+			t.Errorf("track %d: album loudness = %.2f LUFS, want %.2f LUFS", i, results[i].AlbumIntegrated, album)
+		}
+	}
+
+	// A track that cannot be read fails alone, the album is the other tracks.
+	results, errs = ScanAlbum([]string{quiet, missing}, 2)
+	if errs[0] != nil || !errors.Is(errs[1], os.ErrNotExist) {
+		t.Fatalf("ScanAlbum errors = %v, want only %s to fail", errs, missing)
+	}
+	if results[1] != (Loudness{}) {
+		t.Errorf("result of %s = %+v, want none", missing, results[1])
+	}
+	if !results[0].HasAlbum || math.Abs(results[0].AlbumIntegrated+23) > 0.1 {
+		t.Errorf("album loudness without %s = %.2f LUFS, want -23.00 LUFS", missing, results[0].AlbumIntegrated)
+	}
+}
+
+func TestScanAlbums(t *testing.T) {
+	dir := t.TempDir()
+	quiet := filepath.Join(dir, "quiet.flac")
+	loud := filepath.Join(dir, "loud.flac")
+	writeSineFLAC(t, quiet, 44100, 2, 1000, -23, 0, 3*time.Second)
+	writeSineFLAC(t, loud, 44100, 2, 1000, -13, 0, 3*time.Second)
+
+	// Every album is measured on its own, even with the tracks scanned together.
+	albums := [][]string{{quiet}, {loud, loud}, {}}
+	wantAlbums := []float64{-23, -13}
+	var got []int
+	ScanAlbums(albums, 3, func(album int, results []Loudness, errs []error) {
+		got = append(got, album)
+		if len(results) != len(albums[album]) || len(errs) != len(albums[album]) {
+			t.Fatalf("album %d: %d results and %d errors, want %d", album, len(results), len(errs), len(albums[album]))
+		}
+		for i, l := range results {
+			if errs[i] != nil {
+				t.Fatalf("album %d: track %d failed: %v", album, i, errs[i])
+			}
+			if !l.HasAlbum || math.Abs(l.AlbumIntegrated-wantAlbums[album]) > 0.1 {
+				t.Errorf("album %d: album loudness = %.2f LUFS, want %.2f LUFS", album, l.AlbumIntegrated, wantAlbums[album])
+			}
+		}
+	})
+	slices.Sort(got)
+	if !slices.Equal(got, []int{0, 1, 2}) {
+		t.Errorf("done called for albums %v, want each once", got)
+	}
+
+	missing := filepath.Join(dir, "missing.flac")
+	files := []string{quiet, missing, loud}
+	wantTracks := []float64{-23, 0, -13}
+	got = nil
+	ScanTracks(files, 2, func(i int, l Loudness, err error) {
+		got = append(got, i)
+		if files[i] == missing {
+			if !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("ScanTracks error of %s = %v, want %v", missing, err, os.ErrNotExist)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("ScanTracks of %s failed: %v", files[i], err)
+		}
+		if l.HasAlbum || math.Abs(l.Integrated-wantTracks[i]) > 0.1 {
+			t.Errorf("%s: loudness = %+v, want %.2f LUFS and no album", files[i], l, wantTracks[i])
+		}
+	})
+	slices.Sort(got)
+	if !slices.Equal(got, []int{0, 1, 2}) {
+		t.Errorf("done called for files %v, want each once", got)
+	}
+}
+
+func TestLoudnessCache(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "sine.wav")
+	cacheName := filepath.Join(dir, "cache", "loudness.json")
+	writeTestWAV(t, fileName, 44100, time.Second)
+
+	cache, err := LoadLoudnessCache(cacheName)
+	if err != nil {
+		t.Fatalf("LoadLoudnessCache(%s) failed: %v", cacheName, err)
+	}
+	want := Loudness{Integrated: -23, TruePeak: 0.5}
+	if err := cache.Put(fileName, want); err != nil {
+		t.Fatalf("Put(%s) failed: %v", fileName, err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	cache, err = LoadLoudnessCache(cacheName)
+	if err != nil {
+		t.Fatalf("LoadLoudnessCache(%s) failed: %v", cacheName, err)
+	}
+	if got, ok := cache.Get(fileName); !ok || got != want {
+		t.Errorf("Get(%s) = %v, %v, want %v, true", fileName, got, ok, want)
+	}
+
+	// A changed file needs a new scan.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(fileName, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Get(fileName); ok {
+		t.Errorf("Get(%s) found the result of a changed file", fileName)
+	}
+}
+
+func TestPlayer_LoudnessCache(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "sine.wav")
+	writeTestWAV(t, fileName, 44100, time.Second)
+
+	cache, err := LoadLoudnessCache(filepath.Join(dir, "loudness.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Put(fileName, Loudness{Integrated: -23, TruePeak: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+	player.SetLoudnessCache(cache)
+	if err := player.SetReplayGainMode(ReplayGainTrack); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	// -18 LUFS is the reference.
+	if got := player.Info().ReplayGain; math.Abs(got-5) > 1e-9 {
+		t.Errorf("Info().ReplayGain = %v, want 5", got)
+	}
+}
@@ -0,0 +1,234 @@
+package player
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const oggCapture = "OggS"
+
+var errOggSync = errors.New("ogg: lost sync")
+
+// oggPage is a single page of an Ogg bitstream.
+type oggPage struct {
+	headerType byte
+	granule    int64 // -1 when no packet ends on the page
+	lacing     []byte
+	data       []byte
+}
+
+func (p *oggPage) continued() bool {
+	return p.headerType&0x01 != 0
+}
+
+// oggReader reads the packets of an Ogg bitstream with a single logical stream.
+// It only tracks byte offsets of pages, seeking to a sample is up to the codec.
+type oggReader struct {
+	r      io.ReadSeeker
+	br     *bufio.Reader
+	offset int64 // offset of the next byte read from br
+
+	packets [][]byte // complete packets of the last page
+	partial []byte   // packet continued on the next page
+
+	// dropContinued drops the packet continued from the previous page, used
+	// after a seek because its start was never read.
+	dropContinued bool
+}
+
+func newOggReader(r io.ReadSeeker) *oggReader {
+	return &oggReader{r: r, br: bufio.NewReader(r)}
+}
+
+// seek moves to offset, which must be the start of a page.
+func (o *oggReader) seek(offset int64) error {
+	if _, err := o.r.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	o.br.Reset(o.r)
+	o.offset = offset
+	o.packets = o.packets[:0]
+	o.partial = o.partial[:0]
+	o.dropContinued = offset != 0
+	return nil
+}
+
+// sync moves to the first page that starts at or after offset.
+func (o *oggReader) sync(offset int64) error {
+	if err := o.seek(offset); err != nil {
+		return err
+	}
+
+	var window [4]byte
+	for read := 0; ; read++ {
+		b, err := o.br.ReadByte()
+		if err != nil {
+			return err
+		}
+		copy(window[:], window[1:])
+		window[3] = b
+		if read >= 3 && string(window[:]) == oggCapture {
+			return o.seek(offset + int64(read) - 3)
+		}
+	}
+}
+
+// readPage reads the next page, io.EOF is returned at the end of the stream.
+func (o *oggReader) readPage() (*oggPage, error) {
+	var header [27]byte
+	if _, err := io.ReadFull(o.br, header[:]); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != oggCapture {
+		return nil, errOggSync
+	}
+
+	page := &oggPage{
+		headerType: header[5],
+		granule:    int64(binary.LittleEndian.Uint64(header[6:14])),
+		lacing:     make([]byte, header[26]),
+	}
+	if _, err := io.ReadFull(o.br, page.lacing); err != nil {
+		return nil, noEOF(err)
+	}
+
+	size := 0
+	for _, l := range page.lacing {
+		size += int(l)
+	}
+	page.data = make([]byte, size)
+	if _, err := io.ReadFull(o.br, page.data); err != nil {
+		return nil, noEOF(err)
+	}
+
+	o.offset += int64(len(header) + len(page.lacing) + size)
+	return page, nil
+}
+
+// addPage splits page into packets.
+func (o *oggReader) addPage(page *oggPage) {
+	if !page.continued() || o.dropContinued {
+		o.partial = o.partial[:0]
+	}
+
+	drop := page.continued() && o.dropContinued
+	o.dropContinued = false
+
+	data := page.data
+	for _, l := range page.lacing {
+		if !drop {
+			o.partial = append(o.partial, data[:l]...)
+		}
+		data = data[l:]
+
+		if l < 255 {
+			if !drop {
+				o.packets = append(o.packets, bytes.Clone(o.partial))
+			}
+			o.partial = o.partial[:0]
+			drop = false
+		}
+	}
+	// The dropped packet goes on over the next page.
+	o.dropContinued = drop
+}
+
+// nextPacket returns the next complete packet, io.EOF at the end of the stream.
+func (o *oggReader) nextPacket() ([]byte, error) {
+	for len(o.packets) == 0 {
+		page, err := o.readPage()
+		if err == io.EOF && len(o.partial) > 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		o.addPage(page)
+	}
+
+	packet := o.packets[0]
+	o.packets = o.packets[1:]
+	return packet, nil
+}
+
+// oggLastGranule returns the granule position of the last page in r, which is size bytes long.
+func oggLastGranule(r io.ReadSeeker, size int64) (int64, error) {
+	const chunk = 64 * 1024
+
+	for end := size; end > 0; end -= chunk {
+		start := max(end-chunk-27, 0)
+		buf := make([]byte, end-start)
+		if _, err := r.Seek(start, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return 0, err
+		}
+
+		for i := bytes.LastIndex(buf, []byte(oggCapture)); i >= 0; i = bytes.LastIndex(buf[:i], []byte(oggCapture)) {
+			if i+14 > len(buf) || buf[i+4] != 0 {
+				continue
+			}
+			if granule := int64(binary.LittleEndian.Uint64(buf[i+6 : i+14])); granule >= 0 {
+				return granule, nil
+			}
+		}
+	}
+	return 0, errors.New("ogg: no granule position found")
+}
+
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// oggFirstPacket returns the start of the first packet of the Ogg page at the
+// start of header, nil if the page header is incomplete.
+func oggFirstPacket(header []byte) []byte {
+	if len(header) < 27 || string(header[:4]) != oggCapture {
+		return nil
+	}
+	start := 27 + int(header[26])
+	if len(header) <= start || header[26] == 0 {
+		return nil
+	}
+	size := 0
+	for _, l := range header[27:start] {
+		size += int(l)
+		if l < 255 {
+			break
+		}
+	}
+	return header[start:min(start+size, len(header))]
+}
+
+// oggCodecMatcher returns a Match function for Ogg streams whose first packet starts with prefix.
+func oggCodecMatcher(prefix string) func(header []byte) bool {
+	return func(header []byte) bool {
+		return bytes.HasPrefix(oggFirstPacket(header), []byte(prefix))
+	}
+}
+
+// oggTagsReader returns a Tags function for Ogg streams whose second packet
+// is a Vorbis comment after prefix.
+func oggTagsReader(prefix string) func(r io.ReadSeeker) (Tags, error) {
+	return func(r io.ReadSeeker) (Tags, error) {
+		o := newOggReader(r)
+		if _, err := o.nextPacket(); err != nil {
+			return nil, err
+		}
+		packet, err := o.nextPacket()
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.HasPrefix(packet, []byte(prefix)) {
+			return nil, errors.New("ogg: invalid comment header")
+		}
+		return parseVorbisComment(packet[len(prefix):])
+	}
+}
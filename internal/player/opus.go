@@ -0,0 +1,283 @@
+package player
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/faiface/beep"
+	"github.com/pion/opus"
+)
+
+const (
+	opusSampleRate = 48000 // Opus always decodes at 48 kHz
+	opusMaxFrame   = 5760  // 120 ms, the longest Opus packet
+	opusPreroll    = 3840  // 80 ms the decoder needs to converge after a seek
+)
+
+func init() {
+	RegisterFormat(FileFormat{
+		Name:   "opus",
+		Exts:   []string{".opus", ".ogg", ".oga"},
+		Match:  oggCodecMatcher("OpusHead"),
+		Decode: decodeOpus,
+		Tags:   oggTagsReader("OpusTags"),
+	})
+}
+
+// decodeOpus decodes an Ogg Opus stream with one or two channels.
+func decodeOpus(rsc io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+	size, err := rsc.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+	if _, err := rsc.Seek(0, io.SeekStart); err != nil {
+		return nil, beep.Format{}, err
+	}
+
+	d := &opusDecoder{closer: rsc, ogg: newOggReader(rsc), size: size}
+	if err := d.readHeaders(); err != nil {
+		return nil, beep.Format{}, err
+	}
+
+	last, err := oggLastGranule(rsc, size)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+	d.length = max(int(last)-d.preSkip, 0)
+
+	if err := d.ogg.seek(d.dataStart); err != nil {
+		return nil, beep.Format{}, err
+	}
+	d.skip = d.preSkip
+
+	format := beep.Format{
+		SampleRate:  opusSampleRate,
+		NumChannels: d.channels,
+		Precision:   2,
+	}
+	return d, format, nil
+}
+
+type opusDecoder struct {
+	closer io.Closer
+	ogg    *oggReader
+	dec    opus.Decoder
+
+	size      int64 // size of the file
+	dataStart int64 // offset of the first audio page
+	channels  int
+	preSkip   int
+	gain      float64 // output gain from the header
+	length    int
+
+	pcm  []float32
+	buf  [][2]float64 // decoded samples not streamed yet
+	skip int          // decoded samples to drop before streaming
+	pos  int
+	eof  bool
+	err  error
+}
+
+// readHeaders parses the identification and the comment header.
+func (d *opusDecoder) readHeaders() error {
+	head, err := d.ogg.nextPacket()
+	if err != nil {
+		return err
+	}
+	if len(head) < 19 || string(head[:8]) != "OpusHead" {
+		return errors.New("opus: invalid identification header")
+	}
+
+	d.channels = int(head[9])
+	d.preSkip = int(binary.LittleEndian.Uint16(head[10:12]))
+	// Output gain is a Q7.8 number in dB.
+	d.gain = math.Pow(10, float64(int16(binary.LittleEndian.Uint16(head[16:18])))/(20*256))
+	if head[18] != 0 || d.channels < 1 || d.channels > 2 {
+		return errors.New("opus: only mono and stereo streams are supported")
+	}
+
+	tags, err := d.ogg.nextPacket()
+	if err != nil {
+		return err
+	}
+	if len(tags) < 8 || string(tags[:8]) != "OpusTags" {
+		return errors.New("opus: invalid comment header")
+	}
+	// Audio data always starts on a new page.
+	d.dataStart = d.ogg.offset
+
+	d.dec, err = opus.NewDecoderWithOutput(opusSampleRate, d.channels)
+	if err != nil {
+		return err
+	}
+	d.pcm = make([]float32, opusMaxFrame*d.channels)
+	return nil
+}
+
+func (d *opusDecoder) Stream(samples [][2]float64) (n int, ok bool) {
+	if d.err != nil || d.eof {
+		return 0, false
+	}
+
+	for n < len(samples) && d.pos < d.length {
+		if len(d.buf) == 0 {
+			if err := d.decodePacket(); err != nil {
+				if err != io.EOF {
+					d.err = err
+				}
+				break
+			}
+			continue
+		}
+
+		copied := copy(samples[n:min(len(samples), n+d.length-d.pos)], d.buf)
+		d.buf = d.buf[copied:]
+		n += copied
+		d.pos += copied
+	}
+
+	if n == 0 {
+		d.eof = true
+	}
+	return n, n > 0
+}
+
+// decodePacket decodes the next packet into the buffer, dropping d.skip samples.
+func (d *opusDecoder) decodePacket() error {
+	packet, err := d.ogg.nextPacket()
+	if err != nil {
+		return err
+	}
+
+	frames, err := d.dec.DecodeToFloat32(packet, d.pcm)
+	if err != nil {
+		return err
+	}
+
+	if cap(d.buf) < frames {
+		d.buf = make([][2]float64, opusMaxFrame)
+	}
+	d.buf = d.buf[:frames]
+	for i := range d.buf {
+		l := float64(d.pcm[i*d.channels]) * d.gain
+		r := l
+		if d.channels == 2 {
+			r = float64(d.pcm[i*2+1]) * d.gain
+		}
+		d.buf[i] = [2]float64{l, r}
+	}
+
+	skip := min(d.skip, len(d.buf))
+	d.buf = d.buf[skip:]
+	d.skip -= skip
+	return nil
+}
+
+func (d *opusDecoder) Err() error {
+	return d.err
+}
+
+func (d *opusDecoder) Len() int {
+	return d.length
+}
+
+func (d *opusDecoder) Position() int {
+	return d.pos
+}
+
+// Seek finds the last page that ends before p minus the preroll by bisection,
+// decodes from there and drops the samples up to p.
+func (d *opusDecoder) Seek(p int) error {
+	if p < 0 || p > d.length {
+		return errors.New("opus: seek position out of range")
+	}
+
+	target := int64(p + d.preSkip) // granule positions count the pre-skip
+	from := max(target-opusPreroll, 0)
+
+	offset, granule, err := d.findPage(from)
+	if err != nil {
+		return err
+	}
+
+	if offset < 0 {
+		// Nothing ends before from, start over.
+		if err := d.ogg.seek(d.dataStart); err != nil {
+			return err
+		}
+		granule = 0
+	} else {
+		// Only the packet continued on the next page starts at granule,
+		// the packets completed on the page itself are dropped.
+		if err := d.ogg.seek(offset); err != nil {
+			return err
+		}
+		page, err := d.ogg.readPage()
+		if err != nil {
+			return err
+		}
+		d.ogg.addPage(page)
+		d.ogg.packets = d.ogg.packets[:0]
+	}
+
+	if err := d.dec.Init(opusSampleRate, d.channels); err != nil {
+		return err
+	}
+	d.buf = d.buf[:0]
+	d.skip = int(target - granule)
+	d.pos = p
+	d.eof = false
+	d.err = nil
+	return nil
+}
+
+// findPage returns the offset and the granule position of the last page with
+// a granule position not after granule, offset is -1 when there is none.
+func (d *opusDecoder) findPage(granule int64) (offset, pageGranule int64, err error) {
+	offset = -1
+	lo, hi := d.dataStart, d.size
+
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		pageOffset, g, err := d.nextGranulePage(mid)
+		if err == io.EOF || (err == nil && pageOffset >= hi) {
+			hi = mid
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if g <= granule {
+			offset, pageGranule = pageOffset, g
+			lo = pageOffset + 1
+		} else {
+			hi = mid
+		}
+	}
+	return offset, pageGranule, nil
+}
+
+// nextGranulePage finds the first page at or after offset that has a granule position.
+func (d *opusDecoder) nextGranulePage(offset int64) (int64, int64, error) {
+	if err := d.ogg.sync(offset); err != nil {
+		return 0, 0, err
+	}
+	for {
+		pageOffset := d.ogg.offset
+		page, err := d.ogg.readPage()
+		if err != nil {
+			return 0, 0, err
+		}
+		if page.granule >= 0 {
+			return pageOffset, page.granule, nil
+		}
+	}
+}
+
+func (d *opusDecoder) Close() error {
+	return d.closer.Close()
+}
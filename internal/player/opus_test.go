@@ -0,0 +1,162 @@
+package player
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// writeTestOpus writes an Ogg Opus stream of count 20 ms CELT packets.
+// The packets are 300 bytes and pages hold at most 8 segments,
+// so most packets continue on the next page.
+func writeTestOpus(t *testing.T, filename string, preSkip uint16, count int) {
+	t.Helper()
+
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var sequence uint32
+	writePage := func(headerType byte, granule int64, lacing []byte, data []byte) {
+		header := make([]byte, 27, 27+len(lacing))
+		copy(header, oggCapture)
+		header[5] = headerType
+		binary.LittleEndian.PutUint64(header[6:], uint64(granule))
+		binary.LittleEndian.PutUint32(header[14:], 1)
+		binary.LittleEndian.PutUint32(header[18:], sequence)
+		header[26] = byte(len(lacing))
+		sequence++
+
+		if _, err := f.Write(append(append(header, lacing...), data...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	head := []byte("OpusHead\x01\x02\x00\x00\x80\xbb\x00\x00\x00\x00\x00")
+	binary.LittleEndian.PutUint16(head[10:], preSkip)
+	writePage(0x02, 0, []byte{byte(len(head))}, head)
+	tags := []byte("OpusTags\x04\x00\x00\x00test\x00\x00\x00\x00")
+	writePage(0, 0, []byte{byte(len(tags))}, tags)
+
+	var (
+		lacing    []byte
+		data      []byte
+		continued bool
+		granule   = int64(-1)
+		written   int64 // granule positions count the pre-skip samples too
+	)
+	flush := func() {
+		headerType := byte(0)
+		if continued {
+			headerType = 0x01
+		}
+		writePage(headerType, granule, lacing, data)
+		continued = len(lacing) > 0 && lacing[len(lacing)-1] == 255
+		lacing, data, granule = nil, nil, -1
+	}
+
+	for i := 0; i < count; i++ {
+		packet := make([]byte, 300)
+		packet[0] = 31 << 3 // CELT fullband 20 ms, one frame
+		for j := 1; j < len(packet); j++ {
+			packet[j] = byte(i*31 + j*7)
+		}
+
+		for left := len(packet); left >= 0; left -= 255 {
+			size := min(left, 255)
+			lacing = append(lacing, byte(size))
+			data = append(data, packet[len(packet)-left:len(packet)-left+size]...)
+			if size < 255 {
+				written += 960
+				granule = written
+			}
+			if len(lacing) == 8 {
+				flush()
+			}
+			if size < 255 {
+				break
+			}
+		}
+	}
+	if len(lacing) > 0 {
+		flush()
+	}
+}
+
+func TestDecodeOpus_Seek(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.opus")
+	writeTestOpus(t, fileName, 312, 100)
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamer, format, err := decodeOpus(f)
+	if err != nil {
+		t.Fatalf("decodeOpus(%s) failed: %v", fileName, err)
+	}
+	defer streamer.Close()
+
+	if format.SampleRate != 48000 || format.NumChannels != 2 {
+		t.Errorf("format = %+v, want 48000 Hz stereo", format)
+	}
+	if streamer.Len() != 100*960-312 {
+		t.Errorf("Len() = %d, want %d", streamer.Len(), 100*960-312)
+	}
+
+	// Decode everything once as the reference for the seeks.
+	reference := make([][2]float64, streamer.Len())
+	if n, _ := streamer.Stream(reference); n != len(reference) {
+		t.Fatalf("streamed %d samples, want %d", n, len(reference))
+	}
+	if n, ok := streamer.Stream(make([][2]float64, 1)); n != 0 || ok {
+		t.Errorf("Stream() at the end = %d, %v, want 0, false", n, ok)
+	}
+
+	for _, pos := range []int{0, 1000, 45678, 100*960 - 400} {
+		if err := streamer.Seek(pos); err != nil {
+			t.Fatalf("Seek(%d) failed: %v", pos, err)
+		}
+		if streamer.Position() != pos {
+			t.Errorf("Position() = %d, want %d", streamer.Position(), pos)
+		}
+
+		samples := make([][2]float64, streamer.Len()-pos)
+		if n, _ := streamer.Stream(samples); n != len(samples) {
+			t.Fatalf("streamed %d samples after Seek(%d), want %d", n, pos, len(samples))
+		}
+		for i := range samples {
+			if math.Abs(samples[i][0]-reference[pos+i][0]) > 1e-3 {
+				t.Errorf("sample %d after Seek(%d) = %v, want %v", pos+i, pos, samples[i], reference[pos+i])
+				break
+			}
+		}
+	}
+}
+
+func TestPlayer_PlayOggOpus(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test.ogg")
+	writeTestOpus(t, fileName, 312, 50)
+
+	output := NewNullOutput(false)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	completed := make(chan struct{})
+	player.SetOnComplete(func() { close(completed) })
+
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	if want := beep.SampleRate(48000).D(50*960 - 312); player.Info().Length != want {
+		t.Errorf("Info().Length = %v, want %v", player.Info().Length, want)
+	}
+	waitFor(t, completed, 5*time.Second)
+}
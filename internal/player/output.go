@@ -0,0 +1,392 @@
+package player
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+)
+
+// Output is an audio sink that a Player renders into.
+//
+// Lock and Unlock guard the streamers that were handed to Play, the Player
+// holds the lock while it changes anything in its sample chain.
+type Output interface {
+	// Init prepares the output to receive samples at the given sample rate.
+	// bufferSize is the number of samples pulled from the streamers at once.
+	Init(sampleRate beep.SampleRate, bufferSize int) error
+	// Play starts rendering the streamers.
+	Play(s ...beep.Streamer)
+	// Clear removes all streamers from the output.
+	Clear()
+	Lock()
+	Unlock()
+	// Close releases the output, no samples are rendered after Close.
+	Close() error
+}
+
+// SpeakerOutput renders into the system audio device through the beep speaker package.
+//
+// The speaker package is global, so all SpeakerOutput values share the same device.
+type SpeakerOutput struct{}
+
+func NewSpeakerOutput() *SpeakerOutput {
+	return &SpeakerOutput{}
+}
+
+func (o *SpeakerOutput) Init(sampleRate beep.SampleRate, bufferSize int) error {
+	return speaker.Init(sampleRate, bufferSize)
+}
+
+func (o *SpeakerOutput) Play(s ...beep.Streamer) {
+	speaker.Play(s...)
+}
+
+func (o *SpeakerOutput) Clear() {
+	speaker.Clear()
+}
+
+func (o *SpeakerOutput) Lock() {
+	speaker.Lock()
+}
+
+func (o *SpeakerOutput) Unlock() {
+	speaker.Unlock()
+}
+
+// Close closes the device. It must not be called with the output locked,
+// the speaker waits for its update loop, which takes the lock.
+func (o *SpeakerOutput) Close() error {
+	speaker.Close()
+	return nil
+}
+
+// NullOutput consumes samples and throws them away.
+//
+// With realtime set the samples are consumed at the pace of the sample rate,
+// like a sound card would. Otherwise they are consumed as fast as possible,
+// which is useful for tests and offline rendering.
+type NullOutput struct {
+	pump
+}
+
+func NewNullOutput(realtime bool) *NullOutput {
+	o := &NullOutput{}
+	o.realtime = realtime
+	o.write = func(samples [][2]float64) error { return nil }
+	return o
+}
+
+// wavMaxData is the most sample data a WAV file holds, its sizes are 32 bits.
+const wavMaxData = math.MaxUint32 - 36
+
+var errWAVTooLarge = errors.New("wav output: the file would exceed the 4 GiB size limit of WAV")
+
+// WAVOutput writes the rendered samples to a 16-bit stereo WAV file.
+//
+// The sample rate of the file is set by the first call to Init, later calls
+// with a different sample rate fail. Close must be called to finish the file.
+// Rendering stops with an error before the file outgrows the 4 GiB a WAV
+// file can hold, about 6 hours at 48 kHz.
+type WAVOutput struct {
+	pump
+
+	f       *os.File
+	w       *bufio.Writer
+	format  beep.Format
+	buf     []byte
+	written int64
+}
+
+// NewWAVOutput creates the WAV file at filename.
+// realtime has the same meaning as for NullOutput.
+func NewWAVOutput(filename string, realtime bool) (*WAVOutput, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &WAVOutput{
+		f: f,
+		w: bufio.NewWriter(f),
+	}
+	o.realtime = realtime
+	o.write = o.writeSamples
+	return o, nil
+}
+
+func (o *WAVOutput) Init(sampleRate beep.SampleRate, bufferSize int) error {
+	if o.format.SampleRate != 0 {
+		if o.format.SampleRate != sampleRate {
+			return errors.New("wav output: sample rate can not change")
+		}
+		return o.pump.Init(sampleRate, bufferSize)
+	}
+
+	o.format = beep.Format{SampleRate: sampleRate, NumChannels: 2, Precision: 2}
+	if err := o.writeHeader(); err != nil {
+		return err
+	}
+	return o.pump.Init(sampleRate, bufferSize)
+}
+
+// Close stops rendering, completes the WAV header and closes the file.
+func (o *WAVOutput) Close() error {
+	err := o.pump.Close()
+
+	if o.format.SampleRate != 0 {
+		if ferr := o.w.Flush(); ferr != nil && err == nil {
+			err = ferr
+		}
+		if _, serr := o.f.Seek(0, io.SeekStart); serr != nil && err == nil {
+			err = serr
+		}
+		if herr := o.writeHeader(); herr != nil && err == nil {
+			err = herr
+		}
+	}
+
+	if cerr := o.f.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (o *WAVOutput) writeHeader() error {
+	dataSize := uint32(o.written)
+	header := struct {
+		RiffMark      [4]byte
+		FileSize      uint32
+		WaveMark      [4]byte
+		FmtMark       [4]byte
+		FormatSize    int32
+		FormatType    int16
+		NumChans      int16
+		SampleRate    int32
+		ByteRate      int32
+		BytesPerFrame int16
+		BitsPerSample int16
+		DataMark      [4]byte
+		DataSize      uint32
+	}{
+		RiffMark:      [4]byte{'R', 'I', 'F', 'F'},
+		FileSize:      36 + dataSize,
+		WaveMark:      [4]byte{'W', 'A', 'V', 'E'},
+		FmtMark:       [4]byte{'f', 'm', 't', ' '},
+		FormatSize:    16,
+		FormatType:    1,
+		NumChans:      int16(o.format.NumChannels),
+		SampleRate:    int32(o.format.SampleRate),
+		ByteRate:      int32(int(o.format.SampleRate) * o.format.Width()),
+		BytesPerFrame: int16(o.format.Width()),
+		BitsPerSample: int16(o.format.Precision * 8),
+		DataMark:      [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      dataSize,
+	}
+	if err := binary.Write(o.w, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	return o.w.Flush()
+}
+
+func (o *WAVOutput) writeSamples(samples [][2]float64) error {
+	size := len(samples) * o.format.Width()
+	if o.written+int64(size) > wavMaxData {
+		return errWAVTooLarge
+	}
+	if cap(o.buf) < size {
+		o.buf = make([]byte, size)
+	}
+	buf := o.buf[:size]
+	for i, sample := range samples {
+		o.format.EncodeSigned(buf[i*o.format.Width():], sample)
+	}
+
+	n, err := o.w.Write(buf)
+	o.written += int64(n)
+	return err
+}
+
+// pump pulls samples from a mixer on its own goroutine and hands them to write.
+// It is the common part of the outputs that are not backed by an audio device.
+type pump struct {
+	mu         sync.Mutex
+	mixer      mixer
+	samples    [][2]float64
+	sampleRate beep.SampleRate
+
+	realtime bool
+	write    func(samples [][2]float64) error
+
+	done    chan struct{}
+	stopped chan struct{}
+	errMx   sync.Mutex
+	err     error
+}
+
+func (p *pump) Init(sampleRate beep.SampleRate, bufferSize int) error {
+	p.stop()
+
+	if bufferSize <= 0 {
+		return os.ErrInvalid
+	}
+
+	p.mu.Lock()
+	p.mixer = mixer{buf: make([][2]float64, bufferSize)}
+	p.samples = make([][2]float64, bufferSize)
+	p.sampleRate = sampleRate
+	p.mu.Unlock()
+
+	p.done = make(chan struct{})
+	p.stopped = make(chan struct{})
+	go p.run(p.done, p.stopped)
+
+	return nil
+}
+
+func (p *pump) Play(s ...beep.Streamer) {
+	p.mu.Lock()
+	p.mixer.Add(s...)
+	p.mu.Unlock()
+}
+
+func (p *pump) Clear() {
+	p.mu.Lock()
+	p.mixer.Clear()
+	p.mu.Unlock()
+}
+
+func (p *pump) Lock() {
+	p.mu.Lock()
+}
+
+func (p *pump) Unlock() {
+	p.mu.Unlock()
+}
+
+func (p *pump) Close() error {
+	p.stop()
+	return p.Err()
+}
+
+// Err returns the error that stopped rendering, nil while it renders.
+// Nothing is rendered after a failed write, the streamers are left as they are.
+func (p *pump) Err() error {
+	p.errMx.Lock()
+	defer p.errMx.Unlock()
+	return p.err
+}
+
+func (p *pump) stop() {
+	if p.done == nil {
+		return
+	}
+	close(p.done)
+	<-p.stopped
+	p.done = nil
+	p.stopped = nil
+}
+
+func (p *pump) run(done <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+
+	period := p.sampleRate.D(len(p.samples))
+	next := time.Now()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		p.mu.Lock()
+		idle := p.mixer.Len() == 0
+		n := 0
+		if !idle {
+			n = p.mixer.Stream(p.samples)
+		}
+		p.mu.Unlock()
+
+		if idle {
+			// Nothing to render, wait for a streamer without spinning.
+			select {
+			case <-done:
+				return
+			case <-time.After(period):
+			}
+			next = time.Now()
+			continue
+		}
+
+		if err := p.write(p.samples[:n]); err != nil {
+			p.errMx.Lock()
+			p.err = err
+			p.errMx.Unlock()
+			return
+		}
+
+		if !p.realtime {
+			continue
+		}
+
+		next = next.Add(period)
+		if wait := time.Until(next); wait > 0 {
+			select {
+			case <-done:
+				return
+			case <-time.After(wait):
+			}
+		} else if wait < -period {
+			// Fell too far behind, do not try to catch up.
+			next = time.Now()
+		}
+	}
+}
+
+// mixer mixes streamers like beep.Mixer does, but reports how many samples
+// they streamed, so no silence is rendered after the last one drained.
+type mixer struct {
+	streamers []beep.Streamer
+	buf       [][2]float64
+}
+
+func (m *mixer) Len() int {
+	return len(m.streamers)
+}
+
+func (m *mixer) Add(s ...beep.Streamer) {
+	m.streamers = append(m.streamers, s...)
+}
+
+func (m *mixer) Clear() {
+	m.streamers = nil
+}
+
+// Stream mixes the streamers into samples, which must not be longer than the
+// buffer of m, and removes the drained ones. It returns the most samples a
+// streamer streamed, the rest of samples is silence.
+func (m *mixer) Stream(samples [][2]float64) (n int) {
+	clear(samples)
+	for si := 0; si < len(m.streamers); si++ {
+		sn, sok := m.streamers[si].Stream(m.buf[:len(samples)])
+		for i := range m.buf[:sn] {
+			samples[i][0] += m.buf[i][0]
+			samples[i][1] += m.buf[i][1]
+		}
+		n = max(n, sn)
+		if !sok {
+			m.streamers = slices.Delete(m.streamers, si, si+1)
+			si--
+		}
+	}
+	return n
+}
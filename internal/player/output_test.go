@@ -0,0 +1,201 @@
+package player
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+)
+
+// writeTestWAV writes a 440 Hz sine of length d to a 16-bit stereo WAV file.
+func writeTestWAV(t *testing.T, filename string, sampleRate beep.SampleRate, d time.Duration) {
+	t.Helper()
+
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	i := 0
+	sine := beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		for j := range samples {
+			v := 0.5 * math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate))
+			samples[j] = [2]float64{v, v}
+			i++
+		}
+		return len(samples), true
+	})
+
+	format := beep.Format{SampleRate: sampleRate, NumChannels: 2, Precision: 2}
+	if err := wav.Encode(f, beep.Take(sampleRate.N(d), sine), format); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitFor(t *testing.T, ch <-chan struct{}, timeout time.Duration) {
+	t.Helper()
+
+	select {
+	case <-ch:
+	case <-time.After(timeout):
+		t.Fatal("timed out")
+	}
+}
+
+func TestNullOutput_Play(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "sine.wav")
+	writeTestWAV(t, fileName, 44100, 2*time.Second)
+
+	output := NewNullOutput(false)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	completed := make(chan struct{})
+	player.SetOnComplete(func() { close(completed) })
+
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+
+	info := player.Info()
+	if info.Length != 2*time.Second {
+		t.Errorf("Info().Length = %v, want %v", info.Length, 2*time.Second)
+	}
+
+	waitFor(t, completed, time.Second)
+}
+
+func TestWAVOutput_Play(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "sine.wav")
+	outName := filepath.Join(dir, "out.wav")
+	writeTestWAV(t, fileName, 22050, time.Second)
+
+	output, err := NewWAVOutput(outName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	player := NewPlayerWithOutput(output)
+	completed := make(chan struct{})
+	player.SetOnComplete(func() { close(completed) })
+
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	waitFor(t, completed, time.Second)
+
+	if err := output.Close(); err != nil {
+		t.Fatalf("WAVOutput.Close() failed: %v", err)
+	}
+
+	f, err := os.Open(outName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamer, format, err := wav.Decode(f)
+	if err != nil {
+		t.Fatalf("decoding rendered file failed: %v", err)
+	}
+	defer streamer.Close()
+
+	// The track is resampled to the output rate.
+	if format.SampleRate != DefaultSampleRate {
+		t.Errorf("rendered sample rate = %v, want %v", format.SampleRate, DefaultSampleRate)
+	}
+	if want := DefaultSampleRate.N(time.Second); streamer.Len() < want {
+		t.Errorf("rendered %d samples, want at least %d", streamer.Len(), want)
+	}
+}
+
+func TestWAVOutput_NoPadding(t *testing.T) {
+	outName := filepath.Join(t.TempDir(), "out.wav")
+	output, err := NewWAVOutput(outName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := output.Init(DefaultSampleRate, 512); err != nil {
+		t.Fatalf("WAVOutput.Init failed: %v", err)
+	}
+
+	// 1000 samples end within the second buffer, the rest of it is not written.
+	drained := make(chan struct{})
+	output.Play(beep.Seq(beep.Take(1000, beep.Silence(-1)), beep.Callback(func() { close(drained) })))
+	waitFor(t, drained, time.Second)
+	if err := output.Close(); err != nil {
+		t.Fatalf("WAVOutput.Close() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(outName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := binary.LittleEndian.Uint32(data[40:]); size != 1000*4 {
+		t.Errorf("data size = %d bytes, want %d", size, 1000*4)
+	}
+}
+
+func TestPlayer_PlayMixedSampleRates(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "48000.wav")
+	second := filepath.Join(dir, "44100.wav")
+	writeTestWAV(t, first, 48000, time.Second/2)
+	writeTestWAV(t, second, 44100, time.Second/2)
+
+	output, err := NewWAVOutput(filepath.Join(dir, "out.wav"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	for _, fileName := range []string{first, second} {
+		completed := make(chan struct{})
+		player.SetOnComplete(func() { close(completed) })
+
+		if err := player.Play(fileName); err != nil {
+			t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+		}
+		waitFor(t, completed, time.Second)
+	}
+}
+
+func TestWAVOutput_SizeLimit(t *testing.T) {
+	outName := filepath.Join(t.TempDir(), "out.wav")
+	output, err := NewWAVOutput(outName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output.format = beep.Format{SampleRate: DefaultSampleRate, NumChannels: 2, Precision: 2}
+
+	// Pretend the file is one sample short of the limit.
+	output.written = wavMaxData - 4
+	if err := output.writeSamples(make([][2]float64, 2)); !errors.Is(err, errWAVTooLarge) {
+		t.Errorf("writeSamples past the limit = %v, want %v", err, errWAVTooLarge)
+	}
+	if err := output.writeSamples(make([][2]float64, 1)); err != nil {
+		t.Errorf("writeSamples up to the limit failed: %v", err)
+	}
+	if err := output.Close(); err != nil {
+		t.Fatalf("WAVOutput.Close() failed: %v", err)
+	}
+
+	// The sizes of the header fill their 32 bits without wrapping around.
+	data, err := os.ReadFile(outName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := binary.LittleEndian.Uint32(data[4:]); size != math.MaxUint32 {
+		t.Errorf("RIFF size = %d, want %d", size, uint32(math.MaxUint32))
+	}
+	if size := binary.LittleEndian.Uint32(data[40:]); size != wavMaxData {
+		t.Errorf("data size = %d, want %d", size, wavMaxData)
+	}
+}
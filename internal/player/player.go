@@ -3,122 +3,475 @@
 package player
 
 import (
+	"fmt"
+	"math"
 	"os"
-	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/faiface/beep"
-	"github.com/faiface/beep/effects"
-	"github.com/faiface/beep/mp3"
-	"github.com/faiface/beep/speaker"
-	"github.com/faiface/beep/wav"
 )
 
 var (
 	DefaultAudioQuality = 4 // Resampling quality
+
+	// DefaultSampleRate is the sample rate the output is opened with.
+	// Every track is resampled to it.
+	DefaultSampleRate beep.SampleRate = 44100
+
+	// MinSpeed and MaxSpeed bound the playback speed.
+	MinSpeed = 0.5
+	MaxSpeed = 3.0
 )
 
+// speedStep is how much SpeedUp and SpeedDown change the speed.
+const speedStep = 0.25
+
 // Player represents an audio player that can play, pause, and control audio playback.
 type Player struct {
-	sampleRate beep.SampleRate
-	streamer   beep.StreamSeekCloser
-	ctrl       *beep.Ctrl
-	resampler  *beep.Resampler
-	volume     *effects.Volume
-	filepath   string
+	queue     *trackQueue
+	stretch   *timeStretch
+	channels  *channelMixer
+	crossfeed *crossfeed
+	eq        *equalizer
+	effects   *effectChain
+	fader     *fader
+	limiter   *limiter
+	analyzer  *analyzer
+
+	output     Output
+	outputRate beep.SampleRate
+	outputOpen bool
 
 	mx sync.Mutex
 
+	// callbackMx guards the callbacks, they are read on the audio goroutine
+	// which must not wait for p.mx.
+	callbackMx sync.Mutex
 	onComplete func()
-
-	quality     int     // quality is the resampling quality for audio playback.
-	volumeValue float64 // volumeValue is the current volume level.
-	radioValue  float64 // radioValue is the current radio volume level.
+	onAdvance  func(filename string)
+	// lastCallback is closed once the callback called last returned.
+	lastCallback chan struct{}
+	events       eventHub
+
+	quality         int     // quality is the resampling quality for audio playback.
+	volumeValue     float64 // volumeValue is the current volume level.
+	radioValue      float64 // radioValue is the current playback speed.
+	preservePitch   bool    // preservePitch time-stretches instead of resampling to change the speed.
+	eqGains         EQGains // eqGains are the equalizer gains, kept across tracks.
+	limiterOn       bool    // limiterOn keeps the output from clipping when the volume is boosted.
+	fades           Fades   // fades are the ramps of pause, seek and track changes.
+	silenceTrim     SilenceTrim
+	channelMode     ChannelMode
+	balance         float64 // balance is from -1, only the left channel, to 1, only the right one.
+	crossfeedPreset CrossfeedPreset
+	chainEffects    []ChainEffect // chainEffects are the effects of the effects chain, in order.
+
+	replayGainMode ReplayGainMode
+	loudnessCache  *LoudnessCache
 }
 
+// NewPlayer creates a Player that plays through the system speaker. The
+// speaker is shared and stays open once the Player is closed.
 func NewPlayer() *Player {
+	return NewPlayerWithOutput(NewSpeakerOutput())
+}
+
+// NewPlayerWithOutput creates a Player that renders into output. The Player
+// never closes output, the caller does once the Player is closed.
+func NewPlayerWithOutput(output Output) *Player {
 	return &Player{
-		quality:     DefaultAudioQuality,
-		volumeValue: 0,   // Default volume level
-		radioValue:  1.0, // Default radio volume level
+		output:          output,
+		outputRate:      DefaultSampleRate,
+		quality:         DefaultAudioQuality,
+		volumeValue:     0,   // Default volume level
+		radioValue:      1.0, // Default playback speed
+		limiterOn:       true,
+		fades:           DefaultFades,
+		silenceTrim:     SilenceTrim{Threshold: DefaultSilenceThreshold},
+		channelMode:     ChannelsStereo,
+		crossfeedPreset: CrossfeedOff,
+		chainEffects:    defaultEffects(),
+
+		replayGainMode: ReplayGainOff,
 	}
 }
 
 func (p *Player) SetOnComplete(callback func()) {
 	// SetOnComplete sets a callback function to be called when playback completes.
+	p.callbackMx.Lock()
+	defer p.callbackMx.Unlock()
 	p.onComplete = callback
 }
 
+// SetOnAdvance sets a callback function to be called when playback moved on to
+// the queued track. It is called instead of the OnComplete callback.
+func (p *Player) SetOnAdvance(callback func(filename string)) {
+	p.callbackMx.Lock()
+	defer p.callbackMx.Unlock()
+	p.onAdvance = callback
+}
+
 // Play starts playing the audio file specified by filename.
-// It initializes the audio system, opens the file, and starts playback.
+// It opens the output on first use, opens the file, and starts playback.
 func (p *Player) Play(filename string) error {
+	return p.PlaySource(NewFileSource(filename))
+}
+
+// PlaySource starts playing src like Play does a file.
+func (p *Player) PlaySource(src Source) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if err := p.openOutput(); err != nil {
+		return err
+	}
+
+	if p.queue != nil {
+		p.release() // Close any existing stream before playing a new one
+	}
+
+	t, err := p.openTrack(src)
+	if err != nil {
+		p.events.publish(Event{Type: EventDecodeError, Filepath: sourceFilepath(src), Name: src.Name(), Err: err})
+		return err
+	}
+
+	p.queue = &trackQueue{current: t, onEnd: p.trackEnded, onError: p.decodeFailed}
+	p.stretch = newTimeStretch(p.outputRate, p.queue)
+	p.stretch.speed = p.stretchSpeed()
+	p.channels = newChannelMixer(newChannelMatrix(p.channelMode, p.balance), p.stretch)
+	p.fader = newFader(p.volumeValue, p.channels)
+	p.fader.gain = 0
+	p.fader.fadeGain(1, p.outputRate.N(p.fades.Track), nil)
+	p.effects = newEffectChain(p.outputRate, p.chainEffects, p.builtinEffect, p.fader)
+	p.analyzer = newAnalyzer(p.effects)
+
+	p.output.Play(p.analyzer)
+	p.events.publish(Event{Type: EventStarted, Filepath: t.filepath, Name: t.name})
+	return nil
+}
+
+// Queue preloads filename as the track to play after the current one.
+// When the current track ends the queued one is spliced in without a gap.
+// A previously queued track is replaced.
+func (p *Player) Queue(filename string) error {
+	return p.QueueCrossfade(filename, 0, CrossfadeLinear)
+}
+
+// QueueCrossfade preloads filename like Queue, but starts it d before the end
+// of the current track and crossfades the two along curve.
+// The OnAdvance callback is called when the crossfade starts.
+func (p *Player) QueueCrossfade(filename string, d time.Duration, curve CrossfadeCurve) error {
+	return p.QueueSource(NewFileSource(filename), d, curve)
+}
+
+// QueueJoined preloads filename like Queue, for a track that continues the
+// current one, like the next track of a live album. The silence between the
+// two is kept even when silence is trimmed.
+func (p *Player) QueueJoined(filename string) error {
+	return p.queueTrack(NewFileSource(filename), 0, CrossfadeLinear, true)
+}
+
+// QueueSource preloads src like QueueCrossfade does a file, d is 0 for a
+// gapless splice.
+func (p *Player) QueueSource(src Source, d time.Duration, curve CrossfadeCurve) error {
+	return p.queueTrack(src, d, curve, false)
+}
+
+func (p *Player) queueTrack(src Source, d time.Duration, curve CrossfadeCurve, joined bool) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.queue == nil {
+		return os.ErrInvalid // Nothing is playing
+	}
+	if d < 0 {
+		return os.ErrInvalid
+	}
+
+	t, err := p.openTrack(src)
+	if err != nil {
+		p.events.publish(Event{Type: EventDecodeError, Filepath: sourceFilepath(src), Name: src.Name(), Err: err})
+		return err
+	}
+	t.fadeIn = p.outputRate.N(d)
+	t.curve = curve
+	if joined && t.trim != nil {
+		if err := t.trim.keepHead(); err != nil {
+			t.close()
+			return err
+		}
+	}
+
+	p.output.Lock()
+	defer p.output.Unlock()
+
+	if p.queue.drained {
+		t.close()
+		return os.ErrInvalid // Too late, playback has already completed
+	}
+
+	if p.queue.next != nil {
+		p.queue.next.close()
+	}
+	p.queue.next = t
+	p.queue.current.trimTail(!joined)
+	return nil
+}
+
+// ClearQueue drops the queued track, playback completes at the end of the current one.
+func (p *Player) ClearQueue() {
 	p.mx.Lock()
 	defer p.mx.Unlock()
 
-	if p.streamer != nil {
-		p.Close() // Close any existing stream before playing a new one
+	if p.queue == nil {
+		return
 	}
 
-	streamer, format, err := p.loadStreamer(filename)
+	p.output.Lock()
+	defer p.output.Unlock()
+
+	if p.queue.next != nil {
+		p.queue.next.close()
+		p.queue.next = nil
+	}
+	p.queue.current.trimTail(true)
+}
+
+// openTrack loads src and resamples it to the output sample rate.
+func (p *Player) openTrack(src Source) (*track, error) {
+	replayGain := p.trackReplayGain(src)
+	streamer, format, err := openSource(src)
 	if err != nil {
+		return nil, err
+	}
+
+	t := &track{
+		source:     src,
+		name:       src.Name(),
+		filepath:   sourceFilepath(src),
+		streamer:   streamer,
+		sampleRate: format.SampleRate,
+		replayGain: replayGain,
+	}
+	if p.silenceTrim.Enabled {
+		trim, err := newTrimmer(streamer, format.SampleRate, p.silenceTrim.Threshold)
+		if err != nil {
+			streamer.Close()
+			return nil, err
+		}
+		t.streamer, t.trim = trim, trim
+	}
+	t.setGain(t.replayGain.gain(p.replayGainMode), 0)
+	t.loop = &abLoop{s: t.streamer}
+	t.resampler = beep.ResampleRatio(p.quality, p.resampleRatio(t), t.loop)
+	return t, nil
+}
+
+// trackReplayGain reads the ReplayGain tags of src. Files without tags
+// fall back to their loudness scan, if it is cached.
+func (p *Player) trackReplayGain(src Source) ReplayGain {
+	rg := readReplayGain(src)
+	filename := sourceFilepath(src)
+	if rg.HasTrack || rg.HasAlbum || p.loudnessCache == nil || filename == "" {
+		return rg
+	}
+
+	if l, ok := p.loudnessCache.Get(filename); ok {
+		return l.replayGain()
+	}
+	return rg
+}
+
+// trackEnded is called from the audio goroutine when the current track drained
+// or, for a crossfade, when the next track started.
+func (p *Player) trackEnded(ended, next *track) {
+	p.events.publish(Event{Type: EventTrackEnded, Filepath: ended.filepath, Name: ended.name})
+
+	p.callbackMx.Lock()
+	defer p.callbackMx.Unlock()
+
+	if next == nil {
+		if onComplete := p.onComplete; onComplete != nil {
+			p.callBack(onComplete)
+		}
+		return
+	}
+
+	p.events.publish(Event{Type: EventStarted, Filepath: next.filepath, Name: next.name})
+	if onAdvance := p.onAdvance; onAdvance != nil {
+		p.callBack(func() { onAdvance(next.filepath) })
+	}
+}
+
+// callBack calls f on a goroutine of its own once the callback called before
+// returned, so the callbacks see the tracks end in the order they did.
+// p.callbackMx must be held.
+func (p *Player) callBack(f func()) {
+	prev, done := p.lastCallback, make(chan struct{})
+	p.lastCallback = done
+	go func() {
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+		f()
+	}()
+}
+
+// decodeFailed is called from the audio goroutine when a track failed to decode.
+// Playback stops there, the OnComplete callback is not called.
+func (p *Player) decodeFailed(t *track, err *DecodeError) {
+	p.events.publish(Event{Type: EventDecodeError, Filepath: t.filepath, Name: t.name, Position: err.Position, Err: err})
+}
+
+// openOutput opens the output once, at the fixed output sample rate.
+// Tracks are resampled into that rate, so changing tracks never touches the device.
+func (p *Player) openOutput() error {
+	if p.outputOpen {
+		return nil
+	}
+
+	if err := p.output.Init(p.outputRate, p.outputRate.N(time.Second/5)); err != nil {
 		return err
 	}
+	p.outputOpen = true
+	return nil
+}
+
+// resampleRatio converts the track sample rate to the output sample rate.
+// Unless the pitch is preserved, it also changes the speed.
+func (p *Player) resampleRatio(t *track) float64 {
+	ratio := float64(t.sampleRate) / float64(p.outputRate)
+	if !p.preservePitch {
+		ratio *= p.radioValue
+	}
+	return ratio
+}
+
+// stretchSpeed is the speed of the time-stretch, 1 unless the pitch is preserved.
+func (p *Player) stretchSpeed() float64 {
+	if p.preservePitch {
+		return p.radioValue
+	}
+	return 1
+}
 
-	p.filepath = filename
-	p.sampleRate = format.SampleRate
-	p.streamer = streamer
+// applySpeed updates the resamplers and the time-stretch to the current speed.
+func (p *Player) applySpeed() {
+	if p.queue == nil {
+		return
+	}
 
-	//
-	speaker.Init(format.SampleRate, format.SampleRate.N(time.Second/5))
+	p.output.Lock()
+	defer p.output.Unlock()
 
-	p.ctrl = &beep.Ctrl{Streamer: beep.Seq(streamer, beep.Callback(func() {
-		if p.onComplete != nil {
-			go p.onComplete()
+	for _, t := range []*track{p.queue.current, p.queue.next, p.queue.outgoing} {
+		if t != nil {
+			t.resampler.SetRatio(p.resampleRatio(t))
 		}
-	}))}
-	p.resampler = beep.ResampleRatio(p.quality, p.radioValue, p.ctrl)
-	p.volume = &effects.Volume{Streamer: p.resampler, Base: 2, Volume: p.volumeValue}
+	}
+	p.stretch.speed = p.stretchSpeed()
+}
 
-	speaker.Play(p.volume)
+// SetSpeed sets the playback speed, from MinSpeed to MaxSpeed.
+// The position and the length in Info stay in track time at any speed.
+func (p *Player) SetSpeed(speed float64) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if speed < MinSpeed || speed > MaxSpeed {
+		return os.ErrInvalid
+	}
+
+	p.radioValue = speed
+	p.applySpeed()
 	return nil
 }
 
+func (p *Player) SpeedUp() {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.radioValue = min(p.radioValue+speedStep, MaxSpeed)
+	p.applySpeed()
+}
+
+func (p *Player) SpeedDown() {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.radioValue = max(p.radioValue-speedStep, MinSpeed)
+	p.applySpeed()
+}
+
+// SetPreservePitch chooses how the speed is changed. With preserve set the
+// audio is time-stretched and keeps its pitch, which suits speech,
+// otherwise it is resampled and the pitch follows the speed.
+func (p *Player) SetPreservePitch(preserve bool) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.preservePitch = preserve
+	p.applySpeed()
+}
+
+func (p *Player) PreservePitch() bool {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return p.preservePitch
+}
+
+// Pause fades out over Fades.Pause and holds playback.
 func (p *Player) Pause() {
 	p.mx.Lock()
 	defer p.mx.Unlock()
 
-	if p.ctrl == nil {
+	if p.fader == nil {
 		return
 	}
 
-	speaker.Lock()
-	defer speaker.Unlock()
+	p.output.Lock()
+	defer p.output.Unlock()
 
-	p.ctrl.Paused = true
+	if !p.fader.pausing {
+		p.fader.pause(p.outputRate.N(p.fades.Pause))
+		p.events.publish(Event{Type: EventPaused, Filepath: p.queue.current.filepath, Name: p.queue.current.name})
+	}
 }
 
+// Resume fades back in over Fades.Pause.
 func (p *Player) Resume() {
 	p.mx.Lock()
 	defer p.mx.Unlock()
 
-	if p.ctrl == nil {
+	if p.fader == nil {
 		return
 	}
 
-	speaker.Lock()
-	defer speaker.Unlock()
-	p.ctrl.Paused = false
+	p.output.Lock()
+	defer p.output.Unlock()
+
+	if p.fader.pausing {
+		p.fader.resume(p.outputRate.N(p.fades.Pause))
+		p.events.publish(Event{Type: EventResumed, Filepath: p.queue.current.filepath, Name: p.queue.current.name})
+	}
 }
 
 func (p *Player) IsPaused() bool {
 	p.mx.Lock()
 	defer p.mx.Unlock()
 
-	if p.ctrl == nil {
-		return true // If no control, consider it paused
+	if p.fader == nil {
+		return true // If nothing is playing, consider it paused
 	}
-	return p.ctrl.Paused
+
+	p.output.Lock()
+	defer p.output.Unlock()
+	return p.fader.pausing
 }
 
 func (p *Player) VolumeUp() {
@@ -126,136 +479,726 @@ func (p *Player) VolumeUp() {
 	defer p.mx.Unlock()
 
 	p.volumeValue += 0.1
+	p.events.publish(Event{Type: EventVolumeChanged, Volume: p.volumeValue})
+	p.fadeVolume(volumeGlide)
+}
+
+func (p *Player) VolumeDown() {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.volumeValue -= 0.1
+	p.events.publish(Event{Type: EventVolumeChanged, Volume: p.volumeValue})
+	p.fadeVolume(volumeGlide)
+}
+
+func (p *Player) SetVolume(volume float64) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.volumeValue = volume
+	p.events.publish(Event{Type: EventVolumeChanged, Volume: p.volumeValue})
+	p.fadeVolume(volumeGlide)
+}
+
+// FadeTo moves the volume to volume over d, sample by sample. The volume is
+// in the units of SetVolume, so a fade is even to the ear. Info reports the
+// volume the fade is heading to. A fade that starts while pausing applies
+// when the pause completed.
+func (p *Player) FadeTo(volume float64, d time.Duration) error {
+	if d < 0 {
+		return os.ErrInvalid
+	}
 
-	if p.volume == nil {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.volumeValue = volume
+	p.events.publish(Event{Type: EventVolumeChanged, Volume: p.volumeValue})
+	p.fadeVolume(d)
+	return nil
+}
+
+// fadeVolume moves the fader to the volume of the Player over d.
+func (p *Player) fadeVolume(d time.Duration) {
+	if p.fader == nil {
 		return
 	}
+	p.output.Lock()
+	defer p.output.Unlock()
+	p.fader.fadeVolume(p.volumeValue, p.outputRate.N(d))
+}
+
+// SetFades sets the lengths of the fades of pause, seek and track changes.
+func (p *Player) SetFades(fades Fades) error {
+	if fades.Pause < 0 || fades.Seek < 0 || fades.Track < 0 {
+		return os.ErrInvalid
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
 
-	speaker.Lock()
-	defer speaker.Unlock()
+	p.fades = fades
+	return nil
+}
 
-	p.volume.Volume = p.volumeValue
+func (p *Player) Fades() Fades {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return p.fades
 }
 
-func (p *Player) VolumeDown() {
+// SetLimiter turns the limiter of the effects chain on or off, it is on by default.
+// The limiter keeps the output below -1 dBFS, so a volume above 0 dB does not clip.
+func (p *Player) SetLimiter(enabled bool) {
 	p.mx.Lock()
 	defer p.mx.Unlock()
 
-	p.volumeValue -= 0.1
+	p.limiterOn = enabled
 
-	if p.volume == nil {
+	if p.limiter == nil {
 		return
 	}
-	speaker.Lock()
-	defer speaker.Unlock()
+	p.output.Lock()
+	defer p.output.Unlock()
+	p.limiter.enabled = enabled
+}
+
+func (p *Player) Limiter() bool {
+	p.mx.Lock()
+	defer p.mx.Unlock()
 
-	p.volume.Volume = p.volumeValue
+	return p.limiterOn
 }
 
-func (p *Player) SetVolume(volume float64) {
+// SetEQ sets the equalizer gains, each clamped to ±MaxEQGain.
+// The change glides in without clicks and the gains stay for the next tracks.
+func (p *Player) SetEQ(gains EQGains) {
 	p.mx.Lock()
 	defer p.mx.Unlock()
 
-	p.volumeValue = volume
+	p.setEQ(gains)
+}
+
+// SetEQBand sets the gain of one equalizer band, see EQFrequencies.
+func (p *Player) SetEQBand(band int, gain float64) error {
+	if band < 0 || band >= EQBands {
+		return os.ErrInvalid
+	}
 
-	if p.volume == nil {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	gains := p.eqGains
+	gains[band] = gain
+	p.setEQ(gains)
+	return nil
+}
+
+func (p *Player) setEQ(gains EQGains) {
+	p.eqGains = gains.clamp()
+
+	if p.eq == nil {
 		return
 	}
-	speaker.Lock()
-	defer speaker.Unlock()
-	p.volume.Volume = p.volumeValue
+	p.output.Lock()
+	defer p.output.Unlock()
+	p.eq.setGains(p.eqGains)
+}
+
+// EQ returns the equalizer gains.
+func (p *Player) EQ() EQGains {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return p.eqGains
+}
+
+// SetReplayGainMode chooses which ReplayGain tags are applied, before the volume.
+// It also applies to the track playing, gliding to its new gain. A track
+// fading out in a crossfade keeps its gain.
+func (p *Player) SetReplayGainMode(mode ReplayGainMode) error {
+	switch mode {
+	case ReplayGainOff, ReplayGainTrack, ReplayGainAlbum:
+	default:
+		return os.ErrInvalid
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.replayGainMode = mode
+
+	if p.queue == nil {
+		return nil
+	}
+	p.output.Lock()
+	defer p.output.Unlock()
+
+	q := p.queue
+	q.current.setGain(q.current.replayGain.gain(mode), p.outputRate.N(replayGainGlide))
+	if q.next != nil {
+		q.next.setGain(q.next.replayGain.gain(mode), 0)
+	}
+	return nil
+}
+
+// SetLoudnessCache sets the cache of loudness scans used to level tracks
+// without ReplayGain tags, nil turns it off. It applies from the next track.
+func (p *Player) SetLoudnessCache(cache *LoudnessCache) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.loudnessCache = cache
+}
 
+// SetSilenceTrim sets whether tracks skip the silence at their start and
+// end, from the next track on. The threshold must be below 0 dBFS.
+// Positions stay those of the file, the length of a track ends at its last
+// sound.
+func (p *Player) SetSilenceTrim(trim SilenceTrim) error {
+	if trim.Threshold >= 0 {
+		return os.ErrInvalid
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.silenceTrim = trim
+	return nil
+}
+
+func (p *Player) SilenceTrim() SilenceTrim {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return p.silenceTrim
+}
+
+// SetChannelMode sets how the left and right channels are mixed, the change
+// glides in and stays for the next tracks.
+func (p *Player) SetChannelMode(mode ChannelMode) error {
+	switch mode {
+	case ChannelsStereo, ChannelsMono, ChannelsSwap, ChannelsKaraoke:
+	default:
+		return os.ErrInvalid
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.channelMode = mode
+	p.mixChannels()
+	return nil
+}
+
+func (p *Player) ChannelMode() ChannelMode {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return p.channelMode
+}
+
+// SetBalance sets the balance from -1, only the left channel, over 0, both
+// at full level, to 1, only the right one.
+func (p *Player) SetBalance(balance float64) error {
+	if balance < -1 || balance > 1 {
+		return os.ErrInvalid
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.balance = balance
+	p.mixChannels()
+	return nil
+}
+
+func (p *Player) Balance() float64 {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return p.balance
+}
+
+// mixChannels glides the channel mixer to the mode and balance of the Player.
+func (p *Player) mixChannels() {
+	if p.channels == nil {
+		return
+	}
+	p.output.Lock()
+	defer p.output.Unlock()
+	p.channels.set(newChannelMatrix(p.channelMode, p.balance), p.outputRate.N(channelGlide))
+}
+
+// SetCrossfeed sets the headphone crossfeed, CrossfeedOff turns it off.
+// The change glides in and stays for the next tracks.
+func (p *Player) SetCrossfeed(preset CrossfeedPreset) error {
+	switch preset {
+	case CrossfeedOff, CrossfeedLight, CrossfeedMedium, CrossfeedStrong:
+	default:
+		return os.ErrInvalid
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.crossfeedPreset = preset
+	if p.crossfeed == nil {
+		return nil
+	}
+	p.output.Lock()
+	defer p.output.Unlock()
+	p.crossfeed.set(preset, p.outputRate.N(crossfeedGlide))
+	return nil
+}
+
+func (p *Player) Crossfeed() CrossfeedPreset {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return p.crossfeedPreset
+}
+
+// AddEffect adds effect to the effects chain under name, enabled, in front of
+// the limiter so the limiter still catches its peaks. The effects run in
+// order after the volume and stay for the next tracks. A removed built-in
+// effect is added back with its name and a nil effect.
+func (p *Player) AddEffect(name string, effect Effect) error {
+	if !validEffect(name, effect) {
+		return os.ErrInvalid
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.effectIndex(name) >= 0 {
+		return os.ErrExist
+	}
+	i := p.effectIndex(EffectLimiter)
+	if i < 0 {
+		i = len(p.chainEffects)
+	}
+	p.chainEffects = slices.Insert(p.chainEffects, i, ChainEffect{Name: name, Effect: effect, Enabled: true})
+	p.updateEffects()
+	return nil
+}
+
+// RemoveEffect removes the effect called name from the effects chain.
+func (p *Player) RemoveEffect(name string) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	i := p.effectIndex(name)
+	if i < 0 {
+		return os.ErrNotExist
+	}
+	p.chainEffects = slices.Delete(p.chainEffects, i, i+1)
+	p.updateEffects()
+	return nil
+}
+
+// SetEffectEnabled turns the effect called name on or off. A disabled effect
+// is left out of the chain, enabling it again starts it with a fresh state.
+func (p *Player) SetEffectEnabled(name string, enabled bool) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	i := p.effectIndex(name)
+	if i < 0 {
+		return os.ErrNotExist
+	}
+	p.chainEffects[i].Enabled = enabled
+	p.updateEffects()
+	return nil
+}
+
+// MoveEffect moves the effect called name to index in the effects chain,
+// the effects after it move up by one.
+func (p *Player) MoveEffect(name string, index int) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	i := p.effectIndex(name)
+	if i < 0 {
+		return os.ErrNotExist
+	}
+	if index < 0 || index >= len(p.chainEffects) {
+		return os.ErrInvalid
+	}
+	e := p.chainEffects[i]
+	p.chainEffects = slices.Insert(slices.Delete(p.chainEffects, i, i+1), index, e)
+	p.updateEffects()
+	return nil
+}
+
+// SetEffects replaces the effects chain with effects, in order. Running
+// built-in effects keep running with their state, the other effects start
+// with a fresh one as their Effect may have changed. MoveEffect and
+// SetEffectEnabled rearrange the chain without that. Built-in effects left
+// out are removed.
+func (p *Player) SetEffects(effects []ChainEffect) error {
+	for i, e := range effects {
+		if !validEffect(e.Name, e.Effect) {
+			return os.ErrInvalid
+		}
+		if slices.IndexFunc(effects[:i], func(o ChainEffect) bool { return o.Name == e.Name }) >= 0 {
+			return os.ErrExist
+		}
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.chainEffects = slices.Clone(effects)
+	p.rebuildEffects()
+	return nil
+}
+
+// Effects returns the effects chain, in order.
+func (p *Player) Effects() []ChainEffect {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return slices.Clone(p.chainEffects)
+}
+
+func (p *Player) effectIndex(name string) int {
+	return slices.IndexFunc(p.chainEffects, func(e ChainEffect) bool { return e.Name == name })
+}
+
+// validEffect reports whether effect can be in the chain under name. Only the
+// built-in effects come without an Effect, and their names are taken.
+func validEffect(name string, effect Effect) bool {
+	return name != "" && (effect == nil) == isBuiltinEffect(name)
+}
+
+// updateEffects rewires the running effects chain to the effects of the Player.
+func (p *Player) updateEffects() {
+	if p.effects == nil {
+		return
+	}
+	p.output.Lock()
+	defer p.output.Unlock()
+	p.effects.update(p.chainEffects)
+
+	// Built-in effects that left the chain are built again with the current
+	// settings when they come back.
+	if !p.effects.running(EffectCrossfeed) {
+		p.crossfeed = nil
+	}
+	if !p.effects.running(EffectEQ) {
+		p.eq = nil
+	}
+	if !p.effects.running(EffectLimiter) {
+		p.limiter = nil
+	}
+}
+
+// rebuildEffects is updateEffects with the effects other than the built-in
+// ones built again.
+func (p *Player) rebuildEffects() {
+	if p.effects != nil {
+		p.output.Lock()
+		p.effects.restart()
+		p.output.Unlock()
+	}
+	p.updateEffects()
+}
+
+// builtinEffect builds the stage of the built-in effect called name on s.
+// It is called from the effects chain with p.mx held.
+func (p *Player) builtinEffect(name string, s beep.Streamer) beep.Streamer {
+	switch name {
+	case EffectCrossfeed:
+		p.crossfeed = newCrossfeed(p.outputRate, p.crossfeedPreset, s)
+		return p.crossfeed
+	case EffectEQ:
+		p.eq = newEqualizer(p.outputRate, p.eqGains, s)
+		return p.eq
+	case EffectLimiter:
+		p.limiter = newLimiter(p.outputRate, p.limiterOn, s)
+		return p.limiter
+	}
+	return nil
+}
+
+func (p *Player) LoudnessCache() *LoudnessCache {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return p.loudnessCache
+}
+
+func (p *Player) ReplayGainMode() ReplayGainMode {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return p.replayGainMode
 }
 
 func (p *Player) ToPosition(pos time.Duration) error {
 	p.mx.Lock()
 	defer p.mx.Unlock()
 
-	if p.streamer == nil {
+	if p.queue == nil {
 		return os.ErrInvalid // No file loaded
 	}
 
-	speaker.Lock()
-	defer speaker.Unlock()
+	p.output.Lock()
+	defer p.output.Unlock()
 
-	length := p.sampleRate.D(p.streamer.Len())
+	t := p.queue.current
+	length := t.sampleRate.D(t.streamer.Len())
 	if pos < 0 || pos >= length {
 		return os.ErrInvalid // Position out of bounds
 	}
 
-	if err := p.streamer.Seek(p.sampleRate.N(pos)); err != nil {
+	if err := p.seekTrack(t, t.sampleRate.N(pos)); err != nil {
 		return err
 	}
+	p.events.publish(Event{Type: EventSeeked, Filepath: t.filepath, Name: t.name, Position: pos})
 	return nil
 }
 
+// seekTrack moves the current track t to pos. The resampler is built again,
+// the old one still holds input from before the seek.
+func (p *Player) seekTrack(t *track, pos int) error {
+	p.captureSeekTail()
+	if err := t.streamer.Seek(pos); err != nil {
+		return err
+	}
+	t.resampler = beep.ResampleRatio(p.quality, t.resampler.Ratio(), t.loop)
+	p.stretch.reset()
+	return nil
+}
+
+// captureSeekTail keeps the audio that would have followed a seek, the seek
+// crossfades from it over Fades.Seek. Near the end of the track there is
+// nothing to capture, streaming on would end the track.
+func (p *Player) captureSeekTail() {
+	n := p.outputRate.N(p.fades.Seek)
+	if p.queue.current.remaining() <= p.stretch.captureInput(n) {
+		n = 0
+	}
+	p.stretch.captureTail(n)
+}
+
 // ToPositionByOffset moves the playback position by a specified offset.
 func (p *Player) ToPositionByOffset(offset time.Duration) error {
 	p.mx.Lock()
 	defer p.mx.Unlock()
 
-	if p.streamer == nil {
+	if p.queue == nil {
 		return os.ErrInvalid // No file loaded
 	}
 
-	speaker.Lock()
-	defer speaker.Unlock()
+	p.output.Lock()
+	defer p.output.Unlock()
 
-	newPos := p.streamer.Position()
-	newPos += p.sampleRate.N(offset)
+	t := p.queue.current
+	newPos := t.streamer.Position()
+	newPos += t.sampleRate.N(offset)
 
 	if newPos < 0 {
 		newPos = 0
-	} else if newPos >= p.streamer.Len() {
-		newPos = p.streamer.Len() - 1
+	} else if newPos >= t.streamer.Len() {
+		newPos = t.streamer.Len() - 1
 	}
 
-	if err := p.streamer.Seek(newPos); err != nil {
+	if err := p.seekTrack(t, newPos); err != nil {
 		return err
 	}
+	p.events.publish(Event{Type: EventSeeked, Filepath: t.filepath, Name: t.name, Position: t.sampleRate.D(newPos)})
 
 	return nil
 }
 
-// Replay currently loaded audio file.
-func (p *Player) Replay() error {
-	if p.filepath == "" {
+// SetLoop loops the current track between a and b, in track time. Playback
+// jumps back to a when it reaches b, and from anywhere after b.
+// The loop belongs to the track playing, the next track starts without one.
+// While it loops the track does not end, so PlayManager's repeat mode and the
+// queued track take over only once the loop is cleared.
+func (p *Player) SetLoop(a, b time.Duration) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.queue == nil {
+		return os.ErrInvalid // No file loaded
+	}
+
+	p.output.Lock()
+	defer p.output.Unlock()
+
+	t := p.queue.current
+	if a < 0 || b <= a || b > t.sampleRate.D(t.streamer.Len()) {
+		return os.ErrInvalid
+	}
+	t.loop.a, t.loop.b, t.loop.hasA = t.sampleRate.N(a), t.sampleRate.N(b), true
+	return nil
+}
+
+// SetLoopA marks the current position as the start of the loop. A running
+// loop keeps going from the new start, unless it is after the end.
+func (p *Player) SetLoopA() error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.queue == nil {
 		return os.ErrInvalid // No file loaded
 	}
 
-	p.Play(p.filepath)
+	p.output.Lock()
+	defer p.output.Unlock()
 
+	l := p.queue.current.loop
+	l.a, l.hasA = p.queue.current.streamer.Position(), true
+	if l.b <= l.a {
+		l.b = 0
+	}
 	return nil
 }
 
+// SetLoopB marks the current position as the end of the loop and starts it.
+// Without a start the loop starts at the beginning of the track.
+func (p *Player) SetLoopB() error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.queue == nil {
+		return os.ErrInvalid // No file loaded
+	}
+
+	p.output.Lock()
+	defer p.output.Unlock()
+
+	l := p.queue.current.loop
+	pos := p.queue.current.streamer.Position()
+	if !l.hasA {
+		l.a, l.hasA = 0, true
+	}
+	if pos <= l.a {
+		return os.ErrInvalid
+	}
+	l.b = pos
+	return nil
+}
+
+// ClearLoop clears the loop points, playback goes on from where it is.
+func (p *Player) ClearLoop() {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.queue == nil {
+		return
+	}
+
+	p.output.Lock()
+	defer p.output.Unlock()
+
+	p.queue.current.loop.clear()
+}
+
+// Replay currently loaded audio file.
+func (p *Player) Replay() error {
+	p.mx.Lock()
+	var src Source
+	if p.queue != nil {
+		src = p.queue.current.source
+	}
+	p.mx.Unlock()
+	if src == nil {
+		return os.ErrInvalid // No file loaded
+	}
+
+	return p.PlaySource(src)
+}
+
 // Close stops playback and releases resources.
-// It closes the speaker and the streamer, and resets the player state.
+// The current track fades out over Fades.Track, then it is closed along with
+// the queued track. The player state is reset at once, the output stays open.
 func (p *Player) Close() {
-	speaker.Lock()
-	defer speaker.Unlock()
+	p.mx.Lock()
+	defer p.mx.Unlock()
 
-	if p.streamer != nil {
-		p.streamer.Close()
-		p.streamer = nil
-	}
-	if p.ctrl != nil {
-		p.ctrl.Paused = true
+	p.release()
+}
+
+// release is Close with p.mx held.
+func (p *Player) release() {
+	p.output.Lock()
+	defer p.output.Unlock()
+
+	if q := p.queue; q != nil {
+		// The chain keeps playing the fade on its own, it must not call back.
+		q.onEnd = func(*track, *track) {}
+		q.onError = func(*track, *DecodeError) {}
+		if q.next != nil {
+			q.next.close()
+			q.next = nil
+		}
+
+		if q.drained {
+			// The output is done with the chain and will not pull the fade.
+			p.fader.stopped = true
+			q.close()
+		} else {
+			p.fader.stop(p.outputRate.N(p.fades.Track), q.close)
+		}
+		p.queue = nil
 	}
 
-	p.ctrl = nil
-	p.resampler = nil
-	p.volume = nil
-	p.sampleRate = 0
-	p.filepath = ""
+	p.stretch = nil
+	p.channels = nil
+	p.crossfeed = nil
+	p.eq = nil
+	p.effects = nil
+	p.fader = nil
+	p.limiter = nil
+	p.analyzer = nil
+}
+
+// DecodeError is a failure of the decoder in the middle of a track.
+type DecodeError struct {
+	Filename string        // the file, or the name of the Source
+	Position time.Duration // where decoding failed, in track time
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: decoding failed at %v: %v", e.Filename, e.Position, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
 }
 
 type Info struct {
-	Filepath string
-	Current  time.Duration
-	Length   time.Duration
+	Name     string        // the display name of the Source, the file path for a file
+	Filepath string        // empty if the Source is not a file
+	Current  time.Duration // position in track time, whatever the speed
+	Length   time.Duration // length in track time, whatever the speed
 	Volume   float64
 	Speed    float64
-	Paused   bool
+
+	ReplayGain    float64 // gain applied to the track in dB, 0 when none
+	PreservePitch bool
+	ChannelMode   ChannelMode
+	Balance       float64
+	Crossfeed     CrossfeedPreset
+	Limiting      bool // the limiter is turning the volume down to prevent clipping
+	Paused        bool
+
+	// LoopA and LoopB are the points of the A-B loop in track time.
+	// LoopA is only set with HasLoopA, LoopB only while Looping.
+	LoopA    time.Duration
+	LoopB    time.Duration
+	HasLoopA bool
+	Looping  bool
+
+	// Err is the *DecodeError that stopped playback of the track, nil if none.
+	Err error
 }
 
 // Info returns the current playback information.
@@ -264,55 +1207,56 @@ func (p *Player) Info() *Info {
 	p.mx.Lock()
 	defer p.mx.Unlock()
 
-	speaker.Lock()
-	defer speaker.Unlock()
-	if p.streamer == nil {
+	p.output.Lock()
+	defer p.output.Unlock()
+	if p.queue == nil {
 		return &Info{
+			Name:     "",
 			Filepath: "",
 			Current:  0,
 			Length:   0,
 			Volume:   p.volumeValue,
 			Speed:    p.radioValue,
-			Paused:   true,
+
+			PreservePitch: p.preservePitch,
+			ChannelMode:   p.channelMode,
+			Balance:       p.balance,
+			Crossfeed:     p.crossfeedPreset,
+			Paused:        true,
 		}
 	}
-	return &Info{
-		Filepath: p.filepath,
-		Current:  p.sampleRate.D(p.streamer.Position()),
-		Length:   p.sampleRate.D(p.streamer.Len()),
+
+	t := p.queue.current
+	info := &Info{
+		Name:     t.name,
+		Filepath: t.filepath,
+		Current:  t.sampleRate.D(t.streamer.Position()),
+		Length:   t.sampleRate.D(t.streamer.Len()),
 		Volume:   p.volumeValue,
 		Speed:    p.radioValue,
-		Paused:   p.ctrl.Paused,
-	}
-}
 
-// Auto loads the audio file by file format
-// just supports mp3 and wav formats
-func (p *Player) loadStreamer(filename string) (beep.StreamSeekCloser, beep.Format, error) {
-	ext := filepath.Ext(filename)
-	if ext != ".mp3" && ext != ".wav" {
-		return nil, beep.Format{}, os.ErrInvalid
-	}
-
-	f, err := os.Open(filename)
-	if err != nil {
-		return nil, beep.Format{}, err
+		ReplayGain:    20 * math.Log10(t.gainTarget),
+		PreservePitch: p.preservePitch,
+		ChannelMode:   p.channelMode,
+		Balance:       p.balance,
+		Crossfeed:     p.crossfeedPreset,
+		Limiting:      p.limiter != nil && p.limiter.active,
+		Paused:        p.fader.pausing,
 	}
-
-	var streamer beep.StreamSeekCloser
-	var format beep.Format
-
-	switch ext {
-	case ".mp3":
-		streamer, format, err = mp3.Decode(f)
-	case ".wav":
-		streamer, format, err = wav.Decode(f)
+	if l := t.loop; l.hasA {
+		info.LoopA, info.HasLoopA = t.sampleRate.D(l.a), true
+		if l.looping() {
+			info.LoopB, info.Looping = t.sampleRate.D(l.b), true
+		}
 	}
-
-	if err != nil {
-		f.Close() // đóng nếu decode thất bại
-		return nil, beep.Format{}, err
+	if p.queue.err != nil {
+		info.Err = p.queue.err
 	}
+	return info
+}
 
-	return streamer, format, nil
+// Auto loads the audio file by its content
+// supports every registered format, see RegisterFormat
+func loadStreamer(filename string) (beep.StreamSeekCloser, beep.Format, error) {
+	return openSource(NewFileSource(filename))
 }
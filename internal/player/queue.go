@@ -0,0 +1,252 @@
+package player
+
+import (
+	"math"
+
+	"github.com/faiface/beep"
+)
+
+// CrossfadeCurve is the shape of the gain ramps of a crossfade.
+type CrossfadeCurve string
+
+const (
+	CrossfadeLinear     CrossfadeCurve = "linear"      // gains sum up to 1
+	CrossfadeEqualPower CrossfadeCurve = "equal-power" // powers sum up to 1, no dip in loudness
+)
+
+// gains returns the gain of the incoming and the outgoing track at x, from 0 to 1.
+func (c CrossfadeCurve) gains(x float64) (in, out float64) {
+	switch c {
+	case CrossfadeEqualPower:
+		return math.Sin(x * math.Pi / 2), math.Cos(x * math.Pi / 2)
+	default:
+		return x, 1 - x
+	}
+}
+
+// track is an opened audio file and the resampler that brings it to the output sample rate.
+type track struct {
+	source     Source
+	name       string // the name of source
+	filepath   string // the file of source, empty if it is not a file
+	streamer   beep.StreamSeekCloser
+	sampleRate beep.SampleRate
+	trim       *trimmer // the streamer, if silence is trimmed
+	loop       *abLoop  // between streamer and resampler
+	resampler  *beep.Resampler
+
+	// fadeIn is the length of the crossfade into this track in output samples,
+	// 0 for a gapless splice.
+	fadeIn int
+	curve  CrossfadeCurve
+
+	replayGain ReplayGain
+	gain       float64 // linear ReplayGain applied to the track
+	gainTarget float64
+	gainStep   float64
+	gainLeft   int // samples left of the gain glide
+}
+
+func (t *track) close() {
+	t.streamer.Close()
+}
+
+// setGain glides the ReplayGain of the track to g over n samples.
+func (t *track) setGain(g float64, n int) {
+	t.gainTarget = g
+	if n <= 0 {
+		t.gain, t.gainLeft = g, 0
+		return
+	}
+	t.gainStep = (g - t.gain) / float64(n)
+	t.gainLeft = n
+}
+
+// applyGain applies the ReplayGain of the track to samples.
+func (t *track) applyGain(samples [][2]float64) {
+	if t.gain == 1 && t.gainLeft == 0 {
+		return
+	}
+	for i := range samples {
+		if t.gainLeft > 0 {
+			t.gain += t.gainStep
+			if t.gainLeft--; t.gainLeft == 0 {
+				t.gain = t.gainTarget
+			}
+		}
+		samples[i][0] *= t.gain
+		samples[i][1] *= t.gain
+	}
+}
+
+// trimTail sets whether the silence at the end of the track is skipped.
+func (t *track) trimTail(on bool) {
+	if t.trim != nil {
+		t.trim.tail = on
+	}
+}
+
+// remaining returns the number of output samples left in the track.
+// A looping track does not end.
+func (t *track) remaining() int {
+	if t.loop.looping() {
+		return math.MaxInt
+	}
+	return int(float64(t.streamer.Len()-t.streamer.Position()) / t.resampler.Ratio())
+}
+
+// trackQueue streams the current track and, when it drains, splices the next
+// one in on the very next sample, so there is no gap between the two.
+// If the next track has a fadeIn, it starts that much earlier and the two
+// tracks are crossfaded.
+//
+// The queue must only be touched with the output locked.
+type trackQueue struct {
+	current *track
+	next    *track
+	drained bool
+
+	// outgoing is the previous track while it is fading out.
+	outgoing *track
+	fadeLen  int
+	fadePos  int
+	curve    CrossfadeCurve
+	buf      [][2]float64
+
+	// onEnd is called from the audio goroutine when the current track, ended,
+	// was replaced. next is the track that took over, nil if nothing was queued.
+	onEnd func(ended, next *track)
+
+	// err is the decode error that stopped the queue, onError is called with it
+	// from the audio goroutine instead of onEnd.
+	err     *DecodeError
+	onError func(t *track, err *DecodeError)
+}
+
+func (q *trackQueue) Stream(samples [][2]float64) (n int, ok bool) {
+	for n < len(samples) && !q.drained {
+		chunk := samples[n:]
+
+		if q.outgoing == nil && q.next != nil && q.next.fadeIn > 0 {
+			left := q.current.remaining()
+			if left <= q.next.fadeIn {
+				q.startCrossfade()
+				continue
+			}
+			// Stop exactly where the crossfade has to start.
+			if left-q.next.fadeIn < len(chunk) {
+				chunk = chunk[:left-q.next.fadeIn]
+			}
+		}
+
+		sn, sok := q.current.resampler.Stream(chunk)
+		q.current.applyGain(chunk[:sn])
+		if q.outgoing != nil {
+			q.mixOutgoing(chunk[:sn])
+		}
+		n += sn
+		if sok {
+			if sn == 0 {
+				break
+			}
+			continue
+		}
+
+		if err := q.current.loop.Err(); err != nil {
+			q.fail(err)
+			break
+		}
+
+		if q.next == nil {
+			q.drained = true
+			q.closeOutgoing()
+			q.onEnd(q.current, nil)
+			break
+		}
+
+		ended := q.current
+		ended.close()
+		q.current, q.next = q.next, nil
+		q.onEnd(ended, q.current)
+	}
+	return n, n > 0 || !q.drained
+}
+
+func (q *trackQueue) Err() error {
+	return nil
+}
+
+// fail stops the queue after the current track failed to decode. The queued
+// track is dropped, playback must not move on past a broken file unnoticed.
+func (q *trackQueue) fail(err error) {
+	t := q.current
+	q.err = &DecodeError{Filename: t.name, Position: t.sampleRate.D(t.streamer.Position()), Err: err}
+	q.drained = true
+	q.closeOutgoing()
+	if q.next != nil {
+		q.next.close()
+		q.next = nil
+	}
+	q.onError(t, q.err)
+}
+
+// startCrossfade makes the next track the current one and keeps the old one
+// playing as the outgoing track.
+func (q *trackQueue) startCrossfade() {
+	q.outgoing = q.current
+	q.fadeLen = q.next.fadeIn
+	q.fadePos = 0
+	q.curve = q.next.curve
+
+	q.current, q.next = q.next, nil
+	q.onEnd(q.outgoing, q.current)
+}
+
+// mixOutgoing applies the fade-in gain to samples of the current track and
+// mixes in the fading out outgoing track.
+func (q *trackQueue) mixOutgoing(samples [][2]float64) {
+	if cap(q.buf) < len(samples) {
+		q.buf = make([][2]float64, len(samples))
+	}
+	buf := q.buf[:len(samples)]
+	on, ook := q.outgoing.resampler.Stream(buf)
+	q.outgoing.applyGain(buf[:on])
+
+	for i := range samples {
+		x := float64(q.fadePos+i) / float64(q.fadeLen)
+		if x > 1 {
+			x = 1
+		}
+		in, out := q.curve.gains(x)
+
+		samples[i][0] *= in
+		samples[i][1] *= in
+		if i < on {
+			samples[i][0] += buf[i][0] * out
+			samples[i][1] += buf[i][1] * out
+		}
+	}
+
+	q.fadePos += len(samples)
+	if !ook || q.fadePos >= q.fadeLen {
+		q.closeOutgoing()
+	}
+}
+
+func (q *trackQueue) closeOutgoing() {
+	if q.outgoing != nil {
+		q.outgoing.close()
+		q.outgoing = nil
+	}
+}
+
+// close releases the current, the queued and the outgoing track.
+func (q *trackQueue) close() {
+	q.current.close()
+	if q.next != nil {
+		q.next.close()
+		q.next = nil
+	}
+	q.closeOutgoing()
+	q.drained = true
+}
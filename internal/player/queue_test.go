@@ -0,0 +1,136 @@
+package player
+
+import (
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// steppedOutput is an Output that renders only when the test pulls from it,
+// so the test decides when playback moves on instead of the wall clock.
+type steppedOutput struct {
+	mu        sync.Mutex
+	streamers []beep.Streamer
+}
+
+func (o *steppedOutput) Init(sampleRate beep.SampleRate, bufferSize int) error { return nil }
+
+func (o *steppedOutput) Play(s ...beep.Streamer) {
+	o.mu.Lock()
+	o.streamers = append(o.streamers, s...)
+	o.mu.Unlock()
+}
+
+func (o *steppedOutput) Clear() {
+	o.mu.Lock()
+	o.streamers = nil
+	o.mu.Unlock()
+}
+
+func (o *steppedOutput) Lock()        { o.mu.Lock() }
+func (o *steppedOutput) Unlock()      { o.mu.Unlock() }
+func (o *steppedOutput) Close() error { return nil }
+
+// render pulls the streamer played last until it drains and returns its samples.
+func (o *steppedOutput) render(t *testing.T) [][2]float64 {
+	t.Helper()
+
+	var out [][2]float64
+	buf := make([][2]float64, 512)
+	for len(out) < DefaultSampleRate.N(time.Minute) {
+		o.mu.Lock()
+		n, ok := o.streamers[len(o.streamers)-1].Stream(buf)
+		o.mu.Unlock()
+		out = append(out, buf[:n]...)
+		if !ok {
+			return out
+		}
+	}
+	t.Fatal("the stream did not end")
+	return nil
+}
+
+func TestPlayer_QueueGapless(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.wav")
+	second := filepath.Join(dir, "second.wav")
+	writeTestWAV(t, first, 44100, 2*time.Second)
+	writeTestWAV(t, second, 44100, time.Second/2)
+
+	output := &steppedOutput{}
+	player := NewPlayerWithOutput(output)
+	advanced := make(chan string, 1)
+	completed := make(chan struct{})
+	player.SetOnAdvance(func(filename string) { advanced <- filename })
+	player.SetOnComplete(func() { close(completed) })
+
+	if err := player.Play(first); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", first, err)
+	}
+	if err := player.Queue(second); err != nil {
+		t.Fatalf("Player.Queue(%s) failed: %v", second, err)
+	}
+	samples := output.render(t)
+
+	waitFor(t, completed, 5*time.Second)
+	select {
+	case filename := <-advanced:
+		if filename != second {
+			t.Errorf("advanced to %s, want %s", filename, second)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("OnAdvance was not called")
+	}
+
+	// Both tracks back to back.
+	if want := 88200 + 22050; len(samples) != want {
+		t.Errorf("rendered %d samples, want %d", len(samples), want)
+	}
+	if n := audible(samples); n != len(samples) {
+		t.Errorf("audible up to sample %d of %d, want no gap", n, len(samples))
+	}
+}
+
+func TestPlayer_QueueCrossfade(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.wav")
+	second := filepath.Join(dir, "second.wav")
+	writeTestWAV(t, first, 44100, 2*time.Second)
+	writeTestWAV(t, second, 48000, time.Second)
+
+	output := &steppedOutput{}
+	player := NewPlayerWithOutput(output)
+
+	if err := player.Play(first); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", first, err)
+	}
+	if err := player.QueueCrossfade(second, time.Second/2, CrossfadeEqualPower); err != nil {
+		t.Fatalf("Player.QueueCrossfade(%s) failed: %v", second, err)
+	}
+	samples := output.render(t)
+
+	// The tracks overlap by the crossfade. Resampling the second track loses
+	// a few milliseconds at its end.
+	want := DefaultSampleRate.N(2*time.Second + time.Second - time.Second/2)
+	if math.Abs(float64(len(samples)-want)) > float64(DefaultSampleRate.N(3*time.Millisecond)) {
+		t.Errorf("rendered %d samples, want about %d", len(samples), want)
+	}
+}
+
+func TestCrossfadeCurve_Gains(t *testing.T) {
+	for _, x := range []float64{0, 0.25, 0.5, 0.75, 1} {
+		in, out := CrossfadeLinear.gains(x)
+		if math.Abs(in+out-1) > 1e-9 {
+			t.Errorf("linear gains at %v sum to %v, want 1", x, in+out)
+		}
+
+		in, out = CrossfadeEqualPower.gains(x)
+		if math.Abs(in*in+out*out-1) > 1e-9 {
+			t.Errorf("equal-power gains at %v have power %v, want 1", x, in*in+out*out)
+		}
+	}
+}
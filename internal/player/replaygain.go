@@ -0,0 +1,96 @@
+package player
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReplayGainMode chooses which ReplayGain tags the Player applies.
+type ReplayGainMode string
+
+const (
+	ReplayGainOff   ReplayGainMode = "off"
+	ReplayGainTrack ReplayGainMode = "track" // every track at the same loudness, for shuffled playlists
+	ReplayGainAlbum ReplayGainMode = "album" // keeps the loudness differences within an album
+)
+
+// replayGainGlide is how long a change of the ReplayGain of the track playing
+// takes, so it does not click.
+const replayGainGlide = 20 * time.Millisecond
+
+// r128Offset converts R128 gains, relative to -23 LUFS, to the -18 LUFS ReplayGain reference.
+const r128Offset = 5.0
+
+// ReplayGain holds the ReplayGain tags of a track, gains in dB and peaks as sample amplitude.
+// A peak of 0 is unknown.
+type ReplayGain struct {
+	TrackGain float64
+	TrackPeak float64
+	HasTrack  bool
+
+	AlbumGain float64
+	AlbumPeak float64
+	HasAlbum  bool
+}
+
+// parseReplayGain reads the REPLAYGAIN_* tags, or the R128_* tags of Opus files.
+func parseReplayGain(tags Tags) ReplayGain {
+	var rg ReplayGain
+	rg.TrackGain, rg.HasTrack = parseGainTag(tags, "REPLAYGAIN_TRACK_GAIN", "R128_TRACK_GAIN")
+	rg.AlbumGain, rg.HasAlbum = parseGainTag(tags, "REPLAYGAIN_ALBUM_GAIN", "R128_ALBUM_GAIN")
+	rg.TrackPeak, _ = strconv.ParseFloat(strings.TrimSpace(tags["REPLAYGAIN_TRACK_PEAK"]), 64)
+	rg.AlbumPeak, _ = strconv.ParseFloat(strings.TrimSpace(tags["REPLAYGAIN_ALBUM_PEAK"]), 64)
+	return rg
+}
+
+func parseGainTag(tags Tags, key, r128Key string) (float64, bool) {
+	if v, ok := tags[key]; ok {
+		// "-6.54 dB"
+		v = strings.TrimSpace(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(v)), "db"))
+		if gain, err := strconv.ParseFloat(v, 64); err == nil {
+			return gain, true
+		}
+	}
+	if v, ok := tags[r128Key]; ok {
+		// A Q7.8 number, in 1/256 dB.
+		if gain, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return float64(gain)/256 + r128Offset, true
+		}
+	}
+	return 0, false
+}
+
+// readReplayGain reads the ReplayGain tags of src.
+// Tags that cannot be read are treated as missing, they never stop playback.
+func readReplayGain(src Source) ReplayGain {
+	tags, err := readTags(src)
+	if err != nil {
+		return ReplayGain{}
+	}
+	return parseReplayGain(tags)
+}
+
+// gain returns the linear gain to apply in mode. A mode whose tags are
+// missing falls back to the other one, a track without tags is left alone.
+// The gain is lowered if the peak would clip.
+func (rg ReplayGain) gain(mode ReplayGainMode) float64 {
+	var db, peak float64
+	switch {
+	case mode == ReplayGainOff:
+		return 1
+	case mode == ReplayGainAlbum && rg.HasAlbum, !rg.HasTrack && rg.HasAlbum:
+		db, peak = rg.AlbumGain, rg.AlbumPeak
+	case rg.HasTrack:
+		db, peak = rg.TrackGain, rg.TrackPeak
+	default:
+		return 1
+	}
+
+	gain := math.Pow(10, db/20)
+	if peak > 0 && gain*peak > 1 {
+		gain = 1 / peak
+	}
+	return gain
+}
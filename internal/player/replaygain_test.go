@@ -0,0 +1,209 @@
+package player
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/mewkiz/flac/meta"
+)
+
+// vorbisCommentBlock builds a FLAC metadata block holding tags.
+func vorbisCommentBlock(tags ...[2]string) *meta.Block {
+	comment := &meta.VorbisComment{Vendor: "test", Tags: tags}
+
+	// The encoder writes an empty block unless the length is set.
+	length := 4 + len(comment.Vendor) + 4
+	for _, tag := range tags {
+		length += 4 + len(tag[0]) + 1 + len(tag[1])
+	}
+	return &meta.Block{
+		Header: meta.Header{Type: meta.TypeVorbisComment, Length: int64(length)},
+		Body:   comment,
+	}
+}
+
+// id3v2Frame builds an ID3v2.3 or v2.4 frame.
+func id3v2Frame(version byte, id string, body []byte) []byte {
+	frame := append([]byte(id), 0, 0, 0, 0, 0, 0)
+	size := len(body)
+	if version == 4 {
+		frame[4], frame[5], frame[6], frame[7] = byte(size>>21&0x7f), byte(size>>14&0x7f), byte(size>>7&0x7f), byte(size&0x7f)
+	} else {
+		binary.BigEndian.PutUint32(frame[4:], uint32(size))
+	}
+	return append(frame, body...)
+}
+
+// id3v2Tag builds an ID3v2 tag holding frames.
+func id3v2Tag(version byte, frames ...[]byte) []byte {
+	var body []byte
+	for _, frame := range frames {
+		body = append(body, frame...)
+	}
+	size := len(body)
+	header := []byte{'I', 'D', '3', version, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(header, body...)
+}
+
+// utf16TXXX builds the body of a TXXX frame in UTF-16 with a byte order mark.
+func utf16TXXX(desc, value string) []byte {
+	body := []byte{1}
+	for _, s := range []string{desc, value} {
+		body = append(body, 0xff, 0xfe)
+		for _, u := range utf16.Encode([]rune(s)) {
+			body = binary.LittleEndian.AppendUint16(body, u)
+		}
+		body = append(body, 0, 0)
+	}
+	return body
+}
+
+func TestReadTags(t *testing.T) {
+	dir := t.TempDir()
+
+	flacName := filepath.Join(dir, "tagged.flac")
+	writeTestFLAC(t, flacName, 44100, 16, 100*time.Millisecond, vorbisCommentBlock(
+		[2]string{"replaygain_track_gain", "-7.50 dB"},
+		[2]string{"REPLAYGAIN_TRACK_PEAK", "0.9"},
+	))
+
+	// ID3v2.4 in UTF-8 and ID3v2.3 in UTF-16 in front of a WAV file.
+	wavName := filepath.Join(dir, "sine.wav")
+	writeTestWAV(t, wavName, 44100, 100*time.Millisecond)
+	wavData, err := os.ReadFile(wavName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id3v24Name := filepath.Join(dir, "v24.wav")
+	tag := id3v2Tag(4,
+		id3v2Frame(4, "TIT2", []byte("\x03title")),
+		id3v2Frame(4, "TXXX", []byte("\x03REPLAYGAIN_ALBUM_GAIN\x00+2.10 dB")),
+	)
+	if err := os.WriteFile(id3v24Name, append(tag, wavData...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	id3v23Name := filepath.Join(dir, "v23.wav")
+	tag = id3v2Tag(3, id3v2Frame(3, "TXXX", utf16TXXX("replaygain_track_gain", "-3.00 dB")))
+	if err := os.WriteFile(id3v23Name, append(tag, wavData...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filename string
+		key      string
+		value    string
+	}{
+		{flacName, "REPLAYGAIN_TRACK_GAIN", "-7.50 dB"},
+		{flacName, "REPLAYGAIN_TRACK_PEAK", "0.9"},
+		{id3v24Name, "REPLAYGAIN_ALBUM_GAIN", "+2.10 dB"},
+		{id3v23Name, "REPLAYGAIN_TRACK_GAIN", "-3.00 dB"},
+	}
+	for _, tt := range tests {
+		tags, err := readTags(NewFileSource(tt.filename))
+		if err != nil {
+			t.Fatalf("readTags(%s) failed: %v", tt.filename, err)
+		}
+		if tags[tt.key] != tt.value {
+			t.Errorf("readTags(%s)[%s] = %q, want %q", tt.filename, tt.key, tags[tt.key], tt.value)
+		}
+	}
+}
+
+func TestReplayGain_Gain(t *testing.T) {
+	tests := []struct {
+		name string
+		tags Tags
+		mode ReplayGainMode
+		want float64 // dB
+	}{
+		{"off", Tags{"REPLAYGAIN_TRACK_GAIN": "-6 dB"}, ReplayGainOff, 0},
+		{"track", Tags{"REPLAYGAIN_TRACK_GAIN": "-6 dB", "REPLAYGAIN_ALBUM_GAIN": "-4 dB"}, ReplayGainTrack, -6},
+		{"album", Tags{"REPLAYGAIN_TRACK_GAIN": "-6 dB", "REPLAYGAIN_ALBUM_GAIN": "-4 dB"}, ReplayGainAlbum, -4},
+		{"album falls back to track", Tags{"REPLAYGAIN_TRACK_GAIN": "-6 dB"}, ReplayGainAlbum, -6},
+		{"track falls back to album", Tags{"REPLAYGAIN_ALBUM_GAIN": "-4 dB"}, ReplayGainTrack, -4},
+		{"no tags", Tags{}, ReplayGainTrack, 0},
+		{"peak limits the gain", Tags{"REPLAYGAIN_TRACK_GAIN": "+6 dB", "REPLAYGAIN_TRACK_PEAK": "0.8"}, ReplayGainTrack, 20 * math.Log10(1/0.8)},
+		{"r128", Tags{"R128_TRACK_GAIN": "-512"}, ReplayGainTrack, 3},
+	}
+	for _, tt := range tests {
+		got := 20 * math.Log10(parseReplayGain(tt.tags).gain(tt.mode))
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: gain = %v dB, want %v dB", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlayer_ReplayGain(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "tagged.flac")
+	writeTestFLAC(t, fileName, 44100, 16, time.Second, vorbisCommentBlock(
+		[2]string{"REPLAYGAIN_TRACK_GAIN", "-6.00 dB"},
+		[2]string{"REPLAYGAIN_ALBUM_GAIN", "-3.00 dB"},
+	))
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+
+	if err := player.SetReplayGainMode(ReplayGainTrack); err != nil {
+		t.Fatalf("SetReplayGainMode(%v) failed: %v", ReplayGainTrack, err)
+	}
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	if got := player.Info().ReplayGain; math.Abs(got+6) > 1e-9 {
+		t.Errorf("Info().ReplayGain = %v, want -6", got)
+	}
+
+	// Changing the mode applies to the track playing.
+	if err := player.SetReplayGainMode(ReplayGainAlbum); err != nil {
+		t.Fatalf("SetReplayGainMode(%v) failed: %v", ReplayGainAlbum, err)
+	}
+	if got := player.Info().ReplayGain; math.Abs(got+3) > 1e-9 {
+		t.Errorf("Info().ReplayGain = %v, want -3", got)
+	}
+
+	if err := player.SetReplayGainMode("loud"); err == nil {
+		t.Error("SetReplayGainMode(loud) succeeded, want an error")
+	}
+}
+
+func TestPlayer_SetReplayGainModeGlide(t *testing.T) {
+	tags := ReplayGain{TrackGain: -6, HasTrack: true}
+	current := &track{replayGain: tags}
+	outgoing := &track{replayGain: tags}
+	current.setGain(1, 0)
+	outgoing.setGain(1, 0)
+
+	player := NewPlayerWithOutput(&steppedOutput{})
+	player.queue = &trackQueue{current: current, outgoing: outgoing}
+	if err := player.SetReplayGainMode(ReplayGainTrack); err != nil {
+		t.Fatalf("SetReplayGainMode(%v) failed: %v", ReplayGainTrack, err)
+	}
+
+	// The track fading out keeps its gain, the one playing glides to its own.
+	if outgoing.gain != 1 || outgoing.gainLeft != 0 {
+		t.Errorf("gain of the outgoing track = %v, gliding %d samples, want 1", outgoing.gain, outgoing.gainLeft)
+	}
+	want := math.Pow(10, -6.0/20)
+	glide := DefaultSampleRate.N(replayGainGlide)
+	samples := make([][2]float64, glide+100)
+	for i := range samples {
+		samples[i] = [2]float64{1, 1}
+	}
+	current.applyGain(samples)
+	for i := 1; i < len(samples); i++ {
+		if step := samples[i-1][0] - samples[i][0]; step < 0 || step > (1-want)/float64(glide)+1e-9 {
+			t.Fatalf("step of the gain at sample %d = %v, want a glide over %d samples", i, step, glide)
+		}
+	}
+	if got := samples[len(samples)-1][0]; got != want {
+		t.Errorf("gain after the glide = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,118 @@
+package player
+
+import (
+	"math"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+const (
+	// DefaultSilenceThreshold is the level below which a sample counts as
+	// silence, in dBFS. It is far below anything audible but above dither.
+	DefaultSilenceThreshold = -60.0
+
+	silenceScan   = 20 * time.Second      // how far into either end of a track silence is looked for
+	silenceMargin = 10 * time.Millisecond // kept before the first and after the last sound
+)
+
+// SilenceTrim is the setting of skipping silence at the start and end of tracks.
+type SilenceTrim struct {
+	Enabled   bool
+	Threshold float64 // dBFS, quieter samples are silence
+}
+
+// trimmer skips the silence at the ends of a decoder. Positions stay those of
+// the decoder: it starts at start, a seek before start lands on start, and it
+// ends at end, which is also its length.
+//
+// It must only be touched with the output locked once the track plays.
+type trimmer struct {
+	beep.StreamSeekCloser
+	start, end int  // the first sample of sound and the sample after the last one
+	head, tail bool // whether the silence before start and after end is skipped
+}
+
+// newTrimmer finds the silence at the ends of s and moves it to the first
+// sound. A track that fails to decode while it is scanned is left as it is,
+// the error shows once it plays.
+func newTrimmer(s beep.StreamSeekCloser, sampleRate beep.SampleRate, threshold float64) (*trimmer, error) {
+	t := &trimmer{StreamSeekCloser: s, end: s.Len(), head: true, tail: true}
+
+	level := math.Pow(10, threshold/20)
+	scan := sampleRate.N(silenceScan)
+	margin := sampleRate.N(silenceMargin)
+
+	start, ok := findSound(s, scan, level, true)
+	if ok {
+		t.start = max(0, start-margin)
+	}
+
+	tailFrom := max(t.start, s.Len()-scan)
+	if err := s.Seek(tailFrom); err == nil {
+		if last, ok := findSound(s, s.Len()-tailFrom, level, false); ok {
+			t.end = min(s.Len(), tailFrom+last+1+margin)
+		}
+	}
+
+	if err := s.Seek(t.start); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// findSound reads up to n samples of s. It returns the offset of the first
+// sample louder than level, or of the last one if first is false. ok is false
+// if it read nothing louder, or if the decoder failed.
+func findSound(s beep.Streamer, n int, level float64, first bool) (offset int, ok bool) {
+	buf := make([][2]float64, 4096)
+	pos := 0
+	offset = -1
+	for pos < n {
+		sn, sok := s.Stream(buf[:min(len(buf), n-pos)])
+		for i, sample := range buf[:sn] {
+			if math.Abs(sample[0]) > level || math.Abs(sample[1]) > level {
+				offset = pos + i
+				if first {
+					return offset, true
+				}
+			}
+		}
+		pos += sn
+		if !sok {
+			break
+		}
+	}
+	if s.Err() != nil {
+		return 0, false
+	}
+	return offset, offset >= 0
+}
+
+// keepHead plays the silence before start, from the beginning of the decoder.
+func (t *trimmer) keepHead() error {
+	t.head = false
+	return t.StreamSeekCloser.Seek(0)
+}
+
+func (t *trimmer) Stream(samples [][2]float64) (n int, ok bool) {
+	left := t.Len() - t.Position()
+	if left <= 0 {
+		return 0, false
+	}
+	return t.StreamSeekCloser.Stream(samples[:min(len(samples), left)])
+}
+
+func (t *trimmer) Len() int {
+	if t.tail {
+		return t.end
+	}
+	return t.StreamSeekCloser.Len()
+}
+
+func (t *trimmer) Seek(p int) error {
+	if t.head {
+		p = max(p, t.start)
+	}
+	return t.StreamSeekCloser.Seek(p)
+}
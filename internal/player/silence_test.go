@@ -0,0 +1,178 @@
+package player
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+)
+
+// writeSilencedWAV writes a 440 Hz sine of length d with lead of silence
+// before it and tail after it to a 16-bit stereo WAV file.
+func writeSilencedWAV(t *testing.T, filename string, lead, d, tail time.Duration) {
+	t.Helper()
+
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	const sampleRate beep.SampleRate = 44100
+	i := 0
+	sine := beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		for j := range samples {
+			v := 0.5 * math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate))
+			samples[j] = [2]float64{v, v}
+			i++
+		}
+		return len(samples), true
+	})
+
+	s := beep.Seq(beep.Silence(sampleRate.N(lead)), beep.Take(sampleRate.N(d), sine), beep.Silence(sampleRate.N(tail)))
+	format := beep.Format{SampleRate: sampleRate, NumChannels: 2, Precision: 2}
+	if err := wav.Encode(f, s, format); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTrimmer(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "silenced.wav")
+	writeSilencedWAV(t, fileName, 2*time.Second, time.Second, 3*time.Second)
+
+	streamer, format, err := loadStreamer(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer streamer.Close()
+
+	trim, err := newTrimmer(streamer, format.SampleRate, DefaultSilenceThreshold)
+	if err != nil {
+		t.Fatalf("newTrimmer failed: %v", err)
+	}
+
+	// The sine starts on a zero crossing, so its first sound is a sample later.
+	near := func(got int, want time.Duration) bool {
+		return math.Abs(float64(got-format.SampleRate.N(want))) < 10
+	}
+	if !near(trim.start, 2*time.Second-silenceMargin) || !near(trim.end, 3*time.Second+silenceMargin) {
+		t.Errorf("sound from %v to %v, want %v to %v", format.SampleRate.D(trim.start), format.SampleRate.D(trim.end),
+			2*time.Second-silenceMargin, 3*time.Second+silenceMargin)
+	}
+	if trim.Position() != trim.start || trim.Len() != trim.end {
+		t.Errorf("Position() = %d, Len() = %d, want %d and %d", trim.Position(), trim.Len(), trim.start, trim.end)
+	}
+	if err := trim.Seek(0); err != nil || trim.Position() != trim.start {
+		t.Errorf("Seek(0) = %v, Position() = %d, want the start of the sound at %d", err, trim.Position(), trim.start)
+	}
+
+	total := 0
+	buf := make([][2]float64, 1000)
+	for {
+		n, ok := trim.Stream(buf)
+		total += n
+		if !ok {
+			break
+		}
+	}
+	if total != trim.end-trim.start {
+		t.Errorf("streamed %d samples, want %d", total, trim.end-trim.start)
+	}
+
+	if err := trim.keepHead(); err != nil || trim.Position() != 0 {
+		t.Errorf("keepHead() = %v, Position() = %d, want 0", err, trim.Position())
+	}
+	trim.tail = false
+	if trim.Len() != format.SampleRate.N(6*time.Second) {
+		t.Errorf("Len() without trimming the tail = %d, want the whole file", trim.Len())
+	}
+}
+
+func TestPlayer_SilenceTrim(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "first.wav")
+	writeSilencedWAV(t, fileName, 2*time.Second, time.Second, 3*time.Second)
+	nextName := filepath.Join(dir, "next.wav")
+	writeSilencedWAV(t, nextName, 2*time.Second, time.Second, 3*time.Second)
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+
+	if err := player.SetSilenceTrim(SilenceTrim{Enabled: true}); err == nil {
+		t.Error("SetSilenceTrim with a threshold of 0 dBFS succeeded, want an error")
+	}
+	if err := player.SetSilenceTrim(SilenceTrim{Enabled: true, Threshold: DefaultSilenceThreshold}); err != nil {
+		t.Fatalf("SetSilenceTrim failed: %v", err)
+	}
+
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	info := player.Info()
+	if info.Current < 1900*time.Millisecond || info.Length > 3100*time.Millisecond {
+		t.Errorf("Info() = %v of %v, want the sound from 2s to 3s", info.Current, info.Length)
+	}
+
+	// A joined track keeps the silence between the two.
+	if err := player.QueueJoined(nextName); err != nil {
+		t.Fatalf("QueueJoined(%s) failed: %v", nextName, err)
+	}
+	if length := player.Info().Length; length != 6*time.Second {
+		t.Errorf("Info().Length with a joined track queued = %v, want the whole file", length)
+	}
+	if err := player.Queue(nextName); err != nil {
+		t.Fatalf("Queue(%s) failed: %v", nextName, err)
+	}
+	if length := player.Info().Length; length > 3100*time.Millisecond {
+		t.Errorf("Info().Length with a track queued = %v, want the end of the sound", length)
+	}
+}
+
+func TestPlayer_SilenceTrimRender(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "silenced.wav")
+	outName := filepath.Join(dir, "out.wav")
+	writeSilencedWAV(t, fileName, 0, time.Second, 3*time.Second)
+
+	output, err := NewWAVOutput(outName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	player := NewPlayerWithOutput(output)
+	if err := player.SetSilenceTrim(SilenceTrim{Enabled: true, Threshold: DefaultSilenceThreshold}); err != nil {
+		t.Fatalf("SetSilenceTrim failed: %v", err)
+	}
+	completed := make(chan struct{})
+	player.SetOnComplete(func() { close(completed) })
+
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	waitFor(t, completed, 5*time.Second)
+	if err := output.Close(); err != nil {
+		t.Fatalf("WAVOutput.Close() failed: %v", err)
+	}
+
+	f, err := os.Open(outName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamer, format, err := wav.Decode(f)
+	if err != nil {
+		t.Fatalf("decoding rendered file failed: %v", err)
+	}
+	defer streamer.Close()
+
+	// The output renders in whole buffers, so up to one more buffer of silence ends the file.
+	if d := format.SampleRate.D(streamer.Len()); d < time.Second || d > 1300*time.Millisecond {
+		t.Errorf("rendered %v, want the second of sound without the trailing silence", d)
+	}
+}
@@ -0,0 +1,176 @@
+package player
+
+import (
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/faiface/beep"
+)
+
+// Source is audio the Player can play, like a file, an in-memory buffer or
+// a remote file read in ranges.
+type Source interface {
+	// Name is the display name of the audio, like its file path or URL.
+	Name() string
+	// FormatHint names the format the audio is in, by its name like "mp3" or
+	// its extension like ".mp3". The format is detected from the content and
+	// the hint is only used when that fails. It may be empty.
+	FormatHint() string
+	// Open opens the audio at its start. A Source can be open more than once
+	// at a time, each with a position of its own.
+	Open() (io.ReadSeekCloser, error)
+}
+
+// NewFileSource returns the Source of the file filename.
+func NewFileSource(filename string) Source {
+	return &fileSource{filename: filename}
+}
+
+type fileSource struct {
+	filename string
+}
+
+func (s *fileSource) Name() string {
+	return s.filename
+}
+
+// FormatHint is empty, files are always detected by their content.
+func (s *fileSource) FormatHint() string {
+	return ""
+}
+
+func (s *fileSource) Open() (io.ReadSeekCloser, error) {
+	return os.Open(s.filename)
+}
+
+// sourceFilepath returns the file of src, empty if it is not a file.
+func sourceFilepath(src Source) string {
+	if f, ok := src.(*fileSource); ok {
+		return f.filename
+	}
+	return ""
+}
+
+// NewReaderSource returns a Source that reads r, under name and with the
+// format hint hint, see Source. The readers of the Source share r, every
+// read seeks it to the position of the reader, so r must not be used by
+// anything else while the Source is played. Closing a reader leaves r open.
+func NewReaderSource(name, hint string, r io.ReadSeeker) Source {
+	return &readerSource{name: name, hint: hint, r: r}
+}
+
+type readerSource struct {
+	name string
+	hint string
+
+	mx sync.Mutex
+	r  io.ReadSeeker
+}
+
+func (s *readerSource) Name() string {
+	return s.name
+}
+
+func (s *readerSource) FormatHint() string {
+	return s.hint
+}
+
+func (s *readerSource) Open() (io.ReadSeekCloser, error) {
+	return &sourceReader{src: s}, nil
+}
+
+// sourceReader is a reader of a readerSource with a position of its own.
+type sourceReader struct {
+	src *readerSource
+	pos int64
+}
+
+func (r *sourceReader) Read(p []byte) (n int, err error) {
+	r.src.mx.Lock()
+	defer r.src.mx.Unlock()
+
+	if _, err := r.src.r.Seek(r.pos, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err = r.src.r.Read(p)
+	r.pos += int64(n)
+	return n, err
+}
+
+func (r *sourceReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		r.src.mx.Lock()
+		size, err := r.src.r.Seek(0, io.SeekEnd)
+		r.src.mx.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		offset += size
+	default:
+		return 0, os.ErrInvalid
+	}
+	if offset < 0 {
+		return 0, os.ErrInvalid
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func (r *sourceReader) Close() error {
+	return nil
+}
+
+// openSource opens src and decodes it with the format of its content,
+// or else the format of its hint.
+func openSource(src Source) (beep.StreamSeekCloser, beep.Format, error) {
+	rsc, err := src.Open()
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+
+	fileFormat, detected, err := sniffFormat(rsc)
+	if err == nil && fileFormat == nil {
+		fileFormat = hintedFormat(src.FormatHint())
+	}
+	if err == nil && fileFormat == nil {
+		err = &UnsupportedFormatError{Filename: src.Name(), Detected: detected}
+	}
+	if err != nil {
+		rsc.Close()
+		return nil, beep.Format{}, err
+	}
+
+	streamer, format, err := fileFormat.Decode(rsc)
+	if err != nil {
+		rsc.Close() // đóng nếu decode thất bại
+		return nil, beep.Format{}, err
+	}
+
+	return streamer, format, nil
+}
+
+// hintedFormat returns the registered format named by hint, its name or one
+// of its extensions, nil if there is none.
+func hintedFormat(hint string) *FileFormat {
+	hint = strings.ToLower(hint)
+	if hint == "" {
+		return nil
+	}
+
+	formatsMx.RLock()
+	defer formatsMx.RUnlock()
+	for i := range formats {
+		if formats[i].Name == hint || slices.Contains(formats[i].Exts, hint) {
+			f := formats[i]
+			return &f
+		}
+	}
+	return nil
+}
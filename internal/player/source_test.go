@@ -0,0 +1,100 @@
+package player
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReaderSource(t *testing.T) {
+	src := NewReaderSource("memory", "", bytes.NewReader([]byte("0123456789")))
+
+	// Readers of the same Source keep positions of their own.
+	a, _ := src.Open()
+	b, _ := src.Open()
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(a, buf); err != nil || string(buf) != "012" {
+		t.Errorf("first read = %q, %v, want 012", buf, err)
+	}
+	if _, err := b.Seek(-3, io.SeekEnd); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	if _, err := io.ReadFull(b, buf); err != nil || string(buf) != "789" {
+		t.Errorf("read after seeking to the end = %q, %v, want 789", buf, err)
+	}
+	if _, err := io.ReadFull(a, buf); err != nil || string(buf) != "345" {
+		t.Errorf("second read = %q, %v, want 345", buf, err)
+	}
+	if _, err := a.Seek(-1, io.SeekStart); err == nil {
+		t.Error("Seek before the start succeeded, want an error")
+	}
+}
+
+func TestHintedFormat(t *testing.T) {
+	for _, hint := range []string{"wav", ".WAV"} {
+		if f := hintedFormat(hint); f == nil || f.Name != "wav" {
+			t.Errorf("hintedFormat(%q) = %v, want wav", hint, f)
+		}
+	}
+	for _, hint := range []string{"", "aiff"} {
+		if f := hintedFormat(hint); f != nil {
+			t.Errorf("hintedFormat(%q) = %v, want nil", hint, f.Name)
+		}
+	}
+}
+
+func TestPlayer_PlaySource(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "sine.wav")
+	writeTestWAV(t, fileName, 44100, time.Second)
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	defer player.Close()
+	events, cancel := player.Subscribe()
+	defer cancel()
+
+	src := NewReaderSource("sine in memory", "", bytes.NewReader(data))
+	if err := player.PlaySource(src); err != nil {
+		t.Fatalf("PlaySource failed: %v", err)
+	}
+	if info := player.Info(); info.Name != "sine in memory" || info.Filepath != "" || info.Length != time.Second {
+		t.Errorf("Info() = %q at %q of %v, want the source name, no file and 1s", info.Name, info.Filepath, info.Length)
+	}
+	if e := nextEvent(t, events); e.Type != EventStarted || e.Name != "sine in memory" || e.Filepath != "" {
+		t.Errorf("event = %+v, want %v for the source", e, EventStarted)
+	}
+
+	// Replay opens the source again while the old playback fades out.
+	if err := player.Replay(); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if err := player.QueueSource(src, 0, CrossfadeLinear); err != nil {
+		t.Fatalf("QueueSource failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if l := player.Levels(); l.Peak[0] < 0.1 {
+		t.Errorf("Levels() = %+v, want the sine", l)
+	}
+
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	if info := player.Info(); info.Name != fileName || info.Filepath != fileName {
+		t.Errorf("Info() = %q at %q, want %s for both", info.Name, info.Filepath, fileName)
+	}
+
+	bad := NewReaderSource("noise", "", bytes.NewReader(make([]byte, 1000)))
+	if err := player.PlaySource(bad); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("PlaySource of unknown data = %v, want %v", err, ErrUnsupportedFormat)
+	}
+}
@@ -0,0 +1,252 @@
+package player
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// timeStretch changes the tempo of a stream without changing its pitch by
+// WSOLA (waveform similarity overlap-add). Every step it takes a frame of two
+// hops from around the nominal input position, picking the offset that lines
+// up best with how the previous frame would have gone on, and overlap-adds it
+// with a Hann window. At speed 1 the frames line up exactly and the input is
+// passed through unchanged.
+//
+// It must only be touched with the output locked.
+type timeStretch struct {
+	Streamer beep.Streamer
+	speed    float64
+
+	hop    int       // output samples per step, half a frame
+	search int       // how far a frame may move from its nominal position
+	window []float64 // periodic Hann window of one frame
+
+	in      [][2]float64 // buffered input, in[0] is input sample inStart
+	inStart int
+	eof     bool
+
+	nominal float64      // nominal input position of the next frame
+	prev    int          // input position of the previous frame
+	tail    [][2]float64 // windowed second half of the previous frame
+	out     [][2]float64 // finished output not streamed yet
+	buf     [][2]float64 // backing array of out
+	started bool
+	done    bool
+
+	// seekTail is the output that would have followed a seek, it fades out
+	// under the output after the seek.
+	seekTail    [][2]float64
+	seekTailPos int
+}
+
+// newTimeStretch creates a timeStretch for a stream at sampleRate, playing at speed 1.
+func newTimeStretch(sampleRate beep.SampleRate, s beep.Streamer) *timeStretch {
+	hop := sampleRate.N(20 * time.Millisecond)
+	ts := &timeStretch{
+		Streamer: s,
+		speed:    1,
+		hop:      hop,
+		search:   sampleRate.N(10 * time.Millisecond),
+		window:   make([]float64, 2*hop),
+		tail:     make([][2]float64, hop),
+		buf:      make([][2]float64, hop),
+	}
+	for i := range ts.window {
+		ts.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(len(ts.window)))
+	}
+	return ts
+}
+
+func (ts *timeStretch) Stream(samples [][2]float64) (n int, ok bool) {
+	for n < len(samples) {
+		if len(ts.out) == 0 {
+			if ts.done || !ts.step() {
+				break
+			}
+		}
+		copied := copy(samples[n:], ts.out)
+		ts.out = ts.out[copied:]
+		n += copied
+	}
+	if ts.seekTailPos < len(ts.seekTail) {
+		ts.mixTail(samples[:n])
+	}
+	return n, n > 0
+}
+
+func (ts *timeStretch) Err() error {
+	return nil
+}
+
+// captureTail streams the next n samples of output before a seek, the
+// stream crossfades from them into the output after the seek. It includes
+// what is buffered, so the seek continues from what was last heard.
+func (ts *timeStretch) captureTail(n int) {
+	ts.seekTail, ts.seekTailPos = ts.seekTail[:0], 0
+	if n <= 0 {
+		return
+	}
+
+	tail := slices.Grow(ts.seekTail, n)[:n]
+	tn, _ := ts.Stream(tail)
+	ts.seekTail = tail[:tn]
+}
+
+// captureInput is how much input captureTail(n) reads at most, the buffered
+// input aside.
+func (ts *timeStretch) captureInput(n int) int {
+	return int(math.Ceil(float64(n)*ts.speed)) + 3*ts.hop + ts.search
+}
+
+func (ts *timeStretch) mixTail(samples [][2]float64) {
+	for i := range samples {
+		if ts.seekTailPos >= len(ts.seekTail) {
+			break
+		}
+		x := float64(ts.seekTailPos) / float64(len(ts.seekTail))
+		samples[i][0] = samples[i][0]*x + ts.seekTail[ts.seekTailPos][0]*(1-x)
+		samples[i][1] = samples[i][1]*x + ts.seekTail[ts.seekTailPos][1]*(1-x)
+		ts.seekTailPos++
+	}
+}
+
+// reset drops everything buffered, used when the input jumped. A tail
+// captured before is kept.
+func (ts *timeStretch) reset() {
+	ts.in = ts.in[:0]
+	ts.inStart = 0
+	ts.eof = false
+	ts.nominal = 0
+	ts.prev = 0
+	ts.out = nil
+	ts.started = false
+	ts.done = false
+}
+
+// step produces the next hop of output, it returns false when there is nothing left.
+func (ts *timeStretch) step() bool {
+	frame := 2 * ts.hop
+	nominal := int(math.Round(ts.nominal))
+
+	var pos int
+	switch {
+	case !ts.started:
+		pos = nominal
+	case ts.speed == 1:
+		pos = ts.prev + ts.hop
+	default:
+		target := ts.prev + ts.hop
+		lo := max(nominal-ts.search, ts.inStart)
+		hi := nominal + ts.search
+		ts.fill(max(hi, target) + frame)
+		pos = ts.bestMatch(target, lo, hi)
+	}
+
+	ts.fill(pos + frame)
+	end := ts.inStart + len(ts.in)
+	if ts.eof && pos >= end {
+		ts.done = true
+		return false
+	}
+
+	ts.out = ts.buf
+	for i := 0; i < ts.hop; i++ {
+		a, b := ts.at(pos+i), ts.at(pos+ts.hop+i)
+		if !ts.started {
+			// Nothing to overlap with, so the first hop is passed through.
+			ts.tail[i] = [2]float64{a[0] * (1 - ts.window[i]), a[1] * (1 - ts.window[i])}
+		}
+		w := ts.window[i]
+		ts.out[i] = [2]float64{ts.tail[i][0] + a[0]*w, ts.tail[i][1] + a[1]*w}
+		w = ts.window[ts.hop+i]
+		ts.tail[i] = [2]float64{b[0] * w, b[1] * w}
+	}
+	if ts.eof && pos+ts.hop >= end {
+		// The last hop, cut off where the input ends.
+		ts.out = ts.out[:end-pos]
+		ts.done = true
+	}
+
+	ts.started = true
+	ts.prev = pos
+	ts.nominal += float64(ts.hop) * ts.speed
+	ts.discard(min(int(ts.nominal)-ts.search, ts.prev+ts.hop))
+	return true
+}
+
+// bestMatch returns the position from lo to hi whose first hop correlates
+// best with the hop at target, searching coarsely first and then refining.
+func (ts *timeStretch) bestMatch(target, lo, hi int) int {
+	const coarse = 4
+
+	best, bestScore := lo, math.Inf(-1)
+	try := func(pos int) {
+		if pos < lo || pos > hi {
+			return
+		}
+		if score := ts.similarity(target, pos); score > bestScore {
+			best, bestScore = pos, score
+		}
+	}
+
+	for pos := lo; pos <= hi; pos += coarse {
+		try(pos)
+	}
+	center := best
+	for pos := center - coarse + 1; pos < center+coarse; pos++ {
+		if pos != center {
+			try(pos)
+		}
+	}
+	return best
+}
+
+// similarity is the cross-correlation of the mono hops at a and b, normalized by the energy at b.
+func (ts *timeStretch) similarity(a, b int) float64 {
+	var corr, energy float64
+	for i := 0; i < ts.hop; i += 2 {
+		x, y := ts.at(a+i), ts.at(b+i)
+		xm, ym := x[0]+x[1], y[0]+y[1]
+		corr += xm * ym
+		energy += ym * ym
+	}
+	return corr / math.Sqrt(energy+1e-9)
+}
+
+// at returns input sample pos, silence outside of the buffered input.
+func (ts *timeStretch) at(pos int) [2]float64 {
+	i := pos - ts.inStart
+	if i < 0 || i >= len(ts.in) {
+		return [2]float64{}
+	}
+	return ts.in[i]
+}
+
+// fill buffers input up to position end, unless the input ends first.
+func (ts *timeStretch) fill(end int) {
+	for !ts.eof && ts.inStart+len(ts.in) < end {
+		need := end - ts.inStart - len(ts.in)
+		start := len(ts.in)
+		ts.in = slices.Grow(ts.in, need)[:start+need]
+		n, ok := ts.Streamer.Stream(ts.in[start:])
+		ts.in = ts.in[:start+n]
+		if !ok {
+			ts.eof = true
+		} else if n == 0 {
+			break
+		}
+	}
+}
+
+// discard drops the buffered input before position pos.
+func (ts *timeStretch) discard(pos int) {
+	drop := min(pos-ts.inStart, len(ts.in))
+	if drop <= 0 {
+		return
+	}
+	ts.in = ts.in[:copy(ts.in, ts.in[drop:])]
+	ts.inStart += drop
+}
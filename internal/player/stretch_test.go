@@ -0,0 +1,174 @@
+package player
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+)
+
+// renderWAV plays fileName into a WAV file after setup and returns the rendered samples.
+func renderWAV(t *testing.T, fileName string, setup func(p *Player)) [][2]float64 {
+	t.Helper()
+
+	outName := filepath.Join(t.TempDir(), "out.wav")
+	output, err := NewWAVOutput(outName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	player := NewPlayerWithOutput(output)
+	completed := make(chan struct{})
+	player.SetOnComplete(func() { close(completed) })
+	setup(player)
+
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	waitFor(t, completed, 5*time.Second)
+	if err := output.Close(); err != nil {
+		t.Fatalf("WAVOutput.Close() failed: %v", err)
+	}
+
+	f, err := os.Open(outName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamer, _, err := wav.Decode(f)
+	if err != nil {
+		t.Fatalf("decoding rendered file failed: %v", err)
+	}
+	defer streamer.Close()
+
+	samples := make([][2]float64, streamer.Len())
+	n, _ := streamer.Stream(samples)
+	return samples[:n]
+}
+
+// audible returns the number of samples up to the last one that is not silent.
+func audible(samples [][2]float64) int {
+	for i := len(samples) - 1; i >= 0; i-- {
+		if math.Abs(samples[i][0]) > 1e-3 {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// frequency estimates the frequency of a sine from its zero crossings.
+func frequency(samples [][2]float64, sampleRate beep.SampleRate) float64 {
+	crossings := 0
+	for i := 1; i < len(samples); i++ {
+		if (samples[i-1][0] < 0) != (samples[i][0] < 0) {
+			crossings++
+		}
+	}
+	return float64(crossings) / 2 / sampleRate.D(len(samples)).Seconds()
+}
+
+func TestTimeStretch_PassThrough(t *testing.T) {
+	input := make([][2]float64, 10000)
+	for i := range input {
+		input[i] = [2]float64{math.Sin(float64(i) / 10), float64(i%100) / 100}
+	}
+
+	ts := newTimeStretch(44100, beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		n = copy(samples, input)
+		input = input[n:]
+		return n, n > 0
+	}))
+	want := append([][2]float64(nil), input...)
+
+	var got [][2]float64
+	buf := make([][2]float64, 512)
+	for {
+		n, ok := ts.Stream(buf)
+		got = append(got, buf[:n]...)
+		if !ok {
+			break
+		}
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("streamed %d samples, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if math.Abs(got[i][0]-want[i][0]) > 1e-9 || math.Abs(got[i][1]-want[i][1]) > 1e-9 {
+			t.Fatalf("sample %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPlayer_SetSpeed(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "sine.wav")
+	writeTestWAV(t, fileName, 44100, 2*time.Second)
+
+	tests := []struct {
+		name          string
+		speed         float64
+		preservePitch bool
+		frequency     float64
+	}{
+		{"resample", 2, false, 880},
+		{"resample slow", 0.5, false, 220},
+		{"time-stretch", 2, true, 440},
+		{"time-stretch slow", 0.5, true, 440},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples := renderWAV(t, fileName, func(p *Player) {
+				if err := p.SetSpeed(tt.speed); err != nil {
+					t.Fatalf("SetSpeed(%v) failed: %v", tt.speed, err)
+				}
+				p.SetPreservePitch(tt.preservePitch)
+			})
+
+			want := int(float64(DefaultSampleRate.N(2*time.Second)) / tt.speed)
+			if got := audible(samples); math.Abs(float64(got-want)) > float64(DefaultSampleRate.N(50*time.Millisecond)) {
+				t.Errorf("rendered %d samples, want about %d", got, want)
+			}
+			if got := frequency(samples[:want/2], DefaultSampleRate); math.Abs(got-tt.frequency) > 5 {
+				t.Errorf("frequency = %.1f Hz, want %.1f Hz", got, tt.frequency)
+			}
+		})
+	}
+}
+
+func TestPlayer_SpeedInfo(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "sine.wav")
+	writeTestWAV(t, fileName, 44100, 2*time.Second)
+
+	output := NewNullOutput(true)
+	defer output.Close()
+
+	player := NewPlayerWithOutput(output)
+	if err := player.Play(fileName); err != nil {
+		t.Fatalf("Player.Play(%s) failed: %v", fileName, err)
+	}
+	defer player.Close()
+
+	if err := player.SetSpeed(MaxSpeed + 1); err == nil {
+		t.Errorf("SetSpeed(%v) succeeded, want an error", MaxSpeed+1)
+	}
+	for range 10 {
+		player.SpeedUp()
+	}
+	player.SetPreservePitch(true)
+
+	time.Sleep(300 * time.Millisecond)
+	info := player.Info()
+	if info.Speed != MaxSpeed || !info.PreservePitch {
+		t.Errorf("Info() speed = %v, preserve pitch = %v, want %v, true", info.Speed, info.PreservePitch, MaxSpeed)
+	}
+	// Position and length are in track time.
+	if info.Length != 2*time.Second {
+		t.Errorf("Info().Length = %v, want %v", info.Length, 2*time.Second)
+	}
+	if info.Current < 500*time.Millisecond {
+		t.Errorf("Info().Current = %v after 300ms at %vx, want at least 500ms", info.Current, MaxSpeed)
+	}
+}
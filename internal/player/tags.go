@@ -0,0 +1,252 @@
+package player
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// Tags are the text tags of a file, keys are upper case.
+type Tags map[string]string
+
+// readTags reads the ID3v2 tag at the start of src and the tags of its format.
+// Tags of the format win over the ID3v2 ones.
+func readTags(src Source) (Tags, error) {
+	f, err := src.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tags, err := readID3v2(f)
+	if err != nil {
+		return nil, err
+	}
+
+	fileFormat, _, err := sniffFormat(f)
+	if err != nil || fileFormat == nil || fileFormat.Tags == nil {
+		return tags, err
+	}
+
+	offset, err := id3v2Size(f)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	formatTags, err := fileFormat.Tags(f)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range formatTags {
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+// parseVorbisComment parses a Vorbis comment header without its packet type prefix.
+func parseVorbisComment(data []byte) (Tags, error) {
+	errInvalid := errors.New("invalid Vorbis comment")
+	next := func() ([]byte, error) {
+		if len(data) < 4 {
+			return nil, errInvalid
+		}
+		n := binary.LittleEndian.Uint32(data)
+		if uint64(n) > uint64(len(data)-4) {
+			return nil, errInvalid
+		}
+		field := data[4 : 4+n]
+		data = data[4+n:]
+		return field, nil
+	}
+
+	if _, err := next(); err != nil { // vendor
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, errInvalid
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+
+	tags := Tags{}
+	for i := uint32(0); i < count; i++ {
+		comment, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if k, v, ok := strings.Cut(string(comment), "="); ok {
+			tags[strings.ToUpper(k)] = v
+		}
+	}
+	return tags, nil
+}
+
+// readID3v2 reads the user defined text frames (TXXX) of the ID3v2 tag at the
+// start of r, keyed by their description. r is left at an unknown position.
+func readID3v2(r io.ReadSeeker) (Tags, error) {
+	tags := Tags{}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	size, err := id3v2Size(r)
+	if err != nil || size == 0 {
+		return tags, err
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, noEOF(err)
+	}
+
+	version, flags := data[3], data[5]
+	body := data[10:]
+	if flags&0x10 != 0 {
+		body = body[:len(body)-10] // footer
+	}
+	if flags&0x80 != 0 && version < 4 {
+		body = removeUnsync(body)
+	}
+	if flags&0x40 != 0 && version >= 3 {
+		// Skip the extended header.
+		if len(body) < 4 {
+			return tags, nil
+		}
+		n := int(binary.BigEndian.Uint32(body))
+		if version == 4 {
+			n = syncsafe(body[:4])
+		} else {
+			n += 4
+		}
+		if n > len(body) {
+			return tags, nil
+		}
+		body = body[n:]
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+
+	for len(body) >= headerLen && body[0] != 0 {
+		id := string(body[:idLen])
+
+		var frameSize int
+		var frameFlags uint16
+		switch version {
+		case 2:
+			frameSize = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(body[4:8]))
+			frameFlags = binary.BigEndian.Uint16(body[8:10])
+		default:
+			frameSize = syncsafe(body[4:8])
+			frameFlags = binary.BigEndian.Uint16(body[8:10])
+		}
+		if frameSize > len(body)-headerLen {
+			break
+		}
+		frame := body[headerLen : headerLen+frameSize]
+		body = body[headerLen+frameSize:]
+
+		if id != "TXXX" && id != "TXX" {
+			continue
+		}
+		if version == 4 {
+			if frameFlags&0x000c != 0 {
+				continue // compressed or encrypted
+			}
+			if frameFlags&0x0002 != 0 {
+				frame = removeUnsync(frame)
+			}
+			if frameFlags&0x0001 != 0 && len(frame) >= 4 {
+				frame = frame[4:] // data length indicator
+			}
+		} else if version == 3 && frameFlags&0x00c0 != 0 {
+			continue // compressed or encrypted
+		}
+
+		if desc, value, ok := parseTXXX(frame); ok {
+			tags[strings.ToUpper(desc)] = value
+		}
+	}
+	return tags, nil
+}
+
+// parseTXXX splits a TXXX frame into its description and its value.
+func parseTXXX(frame []byte) (desc, value string, ok bool) {
+	if len(frame) < 1 {
+		return "", "", false
+	}
+	encoding, text := frame[0], frame[1:]
+
+	terminator := []byte{0}
+	if encoding == 1 || encoding == 2 {
+		terminator = []byte{0, 0}
+	}
+
+	// UTF-16 terminators are aligned to two bytes.
+	i := 0
+	for {
+		j := bytes.Index(text[i:], terminator)
+		if j < 0 {
+			return "", "", false
+		}
+		i += j
+		if len(terminator) == 1 || i%2 == 0 {
+			break
+		}
+		i++
+	}
+
+	// Drop the optional terminator after the value.
+	rest := text[i+len(terminator):]
+	for len(rest)%len(terminator) == 0 && bytes.HasSuffix(rest, terminator) {
+		rest = rest[:len(rest)-len(terminator)]
+	}
+	return decodeID3Text(encoding, text[:i]), decodeID3Text(encoding, rest), true
+}
+
+// decodeID3Text decodes text in one of the ID3v2 encodings to UTF-8.
+func decodeID3Text(encoding byte, text []byte) string {
+	switch encoding {
+	case 0: // ISO-8859-1
+		runes := make([]rune, len(text))
+		for i, b := range text {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	case 1, 2: // UTF-16 with a byte order mark, UTF-16BE
+		order := binary.ByteOrder(binary.BigEndian)
+		if len(text) >= 2 && text[0] == 0xff && text[1] == 0xfe {
+			order, text = binary.LittleEndian, text[2:]
+		} else if len(text) >= 2 && text[0] == 0xfe && text[1] == 0xff {
+			text = text[2:]
+		}
+		units := make([]uint16, len(text)/2)
+		for i := range units {
+			units[i] = order.Uint16(text[2*i:])
+		}
+		return string(utf16.Decode(units))
+	default: // UTF-8
+		return string(text)
+	}
+}
+
+// removeUnsync undoes the ID3v2 unsynchronisation, which inserts a zero after every 0xff.
+func removeUnsync(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte{0xff, 0x00}, []byte{0xff})
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
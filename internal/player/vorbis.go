@@ -0,0 +1,109 @@
+package player
+
+import (
+	"io"
+
+	"github.com/faiface/beep"
+	"github.com/jfreymuth/oggvorbis"
+)
+
+func init() {
+	RegisterFormat(FileFormat{
+		Name:   "vorbis",
+		Exts:   []string{".ogg", ".oga"},
+		Match:  oggCodecMatcher("\x01vorbis"),
+		Decode: decodeVorbis,
+		Tags:   oggTagsReader("\x03vorbis"),
+	})
+}
+
+// decodeVorbis decodes an Ogg Vorbis stream.
+// Mono streams play on both channels, multichannel streams play their first two channels.
+//
+// beep/vorbis is not used because it assumes every stream is stereo.
+func decodeVorbis(rsc io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+	r, err := oggvorbis.NewReader(rsc)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+
+	format := beep.Format{
+		SampleRate:  beep.SampleRate(r.SampleRate()),
+		NumChannels: r.Channels(),
+		Precision:   2,
+	}
+	d := &vorbisDecoder{
+		closer:   rsc,
+		r:        r,
+		channels: r.Channels(),
+		buf:      make([]float32, 512*r.Channels()),
+	}
+	return d, format, nil
+}
+
+type vorbisDecoder struct {
+	closer   io.Closer
+	r        *oggvorbis.Reader
+	channels int
+	buf      []float32
+	err      error
+}
+
+func (d *vorbisDecoder) Stream(samples [][2]float64) (n int, ok bool) {
+	if d.err != nil {
+		return 0, false
+	}
+
+	for n < len(samples) {
+		frames := min(len(samples)-n, len(d.buf)/d.channels)
+		read, err := d.r.Read(d.buf[:frames*d.channels])
+
+		for i := 0; i < read/d.channels; i++ {
+			frame := d.buf[i*d.channels:]
+			l := float64(frame[0])
+			r := l
+			if d.channels > 1 {
+				r = float64(frame[1])
+			}
+			samples[n] = [2]float64{l, r}
+			n++
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			d.err = err
+			break
+		}
+		if read == 0 {
+			break
+		}
+	}
+	return n, n > 0
+}
+
+func (d *vorbisDecoder) Err() error {
+	return d.err
+}
+
+func (d *vorbisDecoder) Len() int {
+	return int(d.r.Length())
+}
+
+func (d *vorbisDecoder) Position() int {
+	return int(d.r.Position())
+}
+
+// Seek moves to p, a stream that failed to decode plays on from there.
+func (d *vorbisDecoder) Seek(p int) error {
+	if err := d.r.SetPosition(int64(p)); err != nil {
+		return err
+	}
+	d.err = nil
+	return nil
+}
+
+func (d *vorbisDecoder) Close() error {
+	return d.closer.Close()
+}
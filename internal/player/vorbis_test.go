@@ -0,0 +1,67 @@
+package player
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// The fixture is the one second mono test file of github.com/jfreymuth/oggvorbis.
+var vorbisFixture = filepath.Join("testdata", "mono.ogg")
+
+func TestDecodeVorbis(t *testing.T) {
+	streamer, format, err := loadStreamer(vorbisFixture)
+	if err != nil {
+		t.Fatalf("loadStreamer(%s) failed: %v", vorbisFixture, err)
+	}
+	defer streamer.Close()
+
+	if format.SampleRate != 44100 || format.NumChannels != 1 {
+		t.Errorf("format = %+v, want 44100 Hz mono", format)
+	}
+	if streamer.Len() != 44100 {
+		t.Errorf("Len() = %d, want 44100", streamer.Len())
+	}
+
+	all := make([][2]float64, streamer.Len()+100)
+	n, _ := streamer.Stream(all)
+	if n != streamer.Len() {
+		t.Errorf("streamed %d samples, want %d", n, streamer.Len())
+	}
+	if peak(all[:n]) == 0 {
+		t.Error("decoded silence, want sound")
+	}
+	for i, s := range all[:n] {
+		if s[0] != s[1] {
+			t.Fatalf("sample %d = %v, want mono on both channels", i, s)
+		}
+	}
+
+	if err := streamer.Seek(22050); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	if p := streamer.Position(); p != 22050 {
+		t.Errorf("Position() after Seek = %d, want 22050", p)
+	}
+	buf := make([][2]float64, 100)
+	streamer.Stream(buf)
+	for i := range buf {
+		if math.Abs(buf[i][0]-all[22050+i][0]) > 1e-4 {
+			t.Fatalf("sample %d after Seek = %v, want %v", 22050+i, buf[i][0], all[22050+i][0])
+		}
+	}
+
+	// A seek recovers a stream that failed to decode.
+	d := streamer.(*vorbisDecoder)
+	d.err = errors.New("corrupt packet")
+	if n, ok := d.Stream(buf); n != 0 || ok {
+		t.Errorf("Stream after an error = %d, %v, want 0, false", n, ok)
+	}
+	if err := d.Seek(0); err != nil || d.Err() != nil {
+		t.Errorf("Seek = %v, Err() = %v, want both nil", err, d.Err())
+	}
+	if n, ok := d.Stream(buf); n != len(buf) || !ok {
+		t.Errorf("Stream after Seek = %d, %v, want %d, true", n, ok, len(buf))
+	}
+}
@@ -0,0 +1,76 @@
+package player
+
+import (
+	"math"
+	"time"
+)
+
+// WaveformPoints is the number of points ScanWaveform summarizes a track
+// into, more than the columns of a wide terminal.
+const WaveformPoints = 1024
+
+// Waveform is an overview of a track, the lowest and highest sample of
+// either channel over each of its points.
+type Waveform struct {
+	Min      []float64
+	Max      []float64
+	Duration time.Duration
+}
+
+// ScanWaveform decodes filename and summarizes it into points points.
+func ScanWaveform(filename string, points int) (*Waveform, error) {
+	streamer, format, err := loadStreamer(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer streamer.Close()
+
+	length := streamer.Len()
+	w := &Waveform{
+		Min:      make([]float64, points),
+		Max:      make([]float64, points),
+		Duration: format.SampleRate.D(length),
+	}
+	if length <= 0 || points <= 0 {
+		return w, nil
+	}
+
+	buf := make([][2]float64, 4096)
+	pos := 0
+	for {
+		n, ok := streamer.Stream(buf)
+		for _, s := range buf[:n] {
+			i := min(int(int64(pos)*int64(points)/int64(length)), points-1)
+			w.Min[i] = min(w.Min[i], s[0], s[1])
+			w.Max[i] = max(w.Max[i], s[0], s[1])
+			pos++
+		}
+		if !ok {
+			break
+		}
+	}
+	if err := streamer.Err(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Peaks returns the loudest sample of the waveform over each of n columns,
+// from 0 to 1.
+func (w *Waveform) Peaks(n int) []float64 {
+	peaks := make([]float64, max(n, 0))
+	points := len(w.Max)
+	if points == 0 {
+		return peaks
+	}
+
+	for i := range peaks {
+		from := i * points / n
+		to := max(from+1, (i+1)*points/n)
+		for j := from; j < min(to, points); j++ {
+			peaks[i] = max(peaks[i], math.Abs(w.Min[j]), math.Abs(w.Max[j]))
+		}
+		peaks[i] = min(peaks[i], 1)
+	}
+	return peaks
+}
@@ -0,0 +1,141 @@
+package player
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WaveformCache keeps waveforms in a directory, a file per track named after
+// the hash of its absolute path. A waveform only counts while the track keeps
+// its modification time and size. It is safe for concurrent use.
+type WaveformCache struct {
+	dir string
+}
+
+// waveformEntry is the file of a waveform. The points are stored as bytes
+// of int8, a 127th of full scale is plenty for an overview.
+type waveformEntry struct {
+	Path     string        `json:"path"`
+	ModTime  time.Time     `json:"mtime"`
+	Size     int64         `json:"size"`
+	Duration time.Duration `json:"duration"`
+	Min      []byte        `json:"min"`
+	Max      []byte        `json:"max"`
+}
+
+// DefaultWaveformCacheDir returns where waveforms are kept by default, in the
+// user cache directory.
+func DefaultWaveformCacheDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "music_player", "waveforms"), nil
+}
+
+// NewWaveformCache returns the cache in dir, which is created on the first Put.
+func NewWaveformCache(dir string) *WaveformCache {
+	return &WaveformCache{dir: dir}
+}
+
+// Get returns the waveform of filename if the file did not change since it was scanned.
+func (c *WaveformCache) Get(filename string) (*Waveform, bool) {
+	key, info, err := cacheKey(filename)
+	if err != nil {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(c.entryFile(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry waveformEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	if entry.Path != key || !entry.ModTime.Equal(info.ModTime()) || entry.Size != info.Size() || len(entry.Min) != len(entry.Max) {
+		return nil, false
+	}
+
+	return &Waveform{Min: unpackPoints(entry.Min), Max: unpackPoints(entry.Max), Duration: entry.Duration}, true
+}
+
+// Put writes the waveform of filename, through a temporary file so a reader
+// never sees half of it.
+func (c *WaveformCache) Put(filename string, w *Waveform) error {
+	key, info, err := cacheKey(filename)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(waveformEntry{
+		Path:     key,
+		ModTime:  info.ModTime(),
+		Size:     info.Size(),
+		Duration: w.Duration,
+		Min:      packPoints(w.Min),
+		Max:      packPoints(w.Max),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.entryFile(key))
+}
+
+// Load returns the waveform of filename from the cache, or scans it into the
+// cache. A waveform that could not be written is still returned.
+func (c *WaveformCache) Load(filename string) (*Waveform, error) {
+	if w, ok := c.Get(filename); ok {
+		return w, nil
+	}
+
+	w, err := ScanWaveform(filename, WaveformPoints)
+	if err != nil {
+		return nil, err
+	}
+	c.Put(filename, w)
+	return w, nil
+}
+
+func (c *WaveformCache) entryFile(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+func packPoints(points []float64) []byte {
+	packed := make([]byte, len(points))
+	for i, v := range points {
+		packed[i] = byte(int8(math.Round(max(-1, min(v, 1)) * 127)))
+	}
+	return packed
+}
+
+func unpackPoints(packed []byte) []float64 {
+	points := make([]float64, len(packed))
+	for i, b := range packed {
+		points[i] = float64(int8(b)) / 127
+	}
+	return points
+}
@@ -0,0 +1,79 @@
+package player
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScanWaveform(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "sine.flac")
+	writeSineFLAC(t, fileName, 44100, 2, 440, -6, 0, time.Second)
+
+	w, err := ScanWaveform(fileName, 100)
+	if err != nil {
+		t.Fatalf("ScanWaveform(%s) failed: %v", fileName, err)
+	}
+	if len(w.Min) != 100 || len(w.Max) != 100 {
+		t.Fatalf("ScanWaveform returned %d and %d points, want 100", len(w.Min), len(w.Max))
+	}
+	if w.Duration != time.Second {
+		t.Errorf("Duration = %v, want 1s", w.Duration)
+	}
+
+	amplitude := math.Pow(10, -6.0/20)
+	for i := range w.Max {
+		if math.Abs(w.Max[i]-amplitude) > 0.01 || math.Abs(w.Min[i]+amplitude) > 0.01 {
+			t.Fatalf("point %d = %v to %v, want -%v to %v", i, w.Min[i], w.Max[i], amplitude, amplitude)
+		}
+	}
+
+	peaks := w.Peaks(7)
+	if len(peaks) != 7 {
+		t.Fatalf("Peaks(7) returned %d peaks", len(peaks))
+	}
+	for i, p := range peaks {
+		if math.Abs(p-amplitude) > 0.01 {
+			t.Errorf("peak %d = %v, want %v", i, p, amplitude)
+		}
+	}
+}
+
+func TestWaveformCache(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "sine.flac")
+	writeSineFLAC(t, fileName, 44100, 2, 440, -6, 0, time.Second)
+
+	cache := NewWaveformCache(filepath.Join(dir, "waveforms"))
+	if _, ok := cache.Get(fileName); ok {
+		t.Fatalf("Get(%s) found a waveform in an empty cache", fileName)
+	}
+
+	want, err := cache.Load(fileName)
+	if err != nil {
+		t.Fatalf("Load(%s) failed: %v", fileName, err)
+	}
+	got, ok := cache.Get(fileName)
+	if !ok {
+		t.Fatalf("Get(%s) found nothing after Load", fileName)
+	}
+	if got.Duration != want.Duration || len(got.Max) != WaveformPoints {
+		t.Fatalf("Get(%s) = %v with %d points, want %v with %d", fileName, got.Duration, len(got.Max), want.Duration, WaveformPoints)
+	}
+	for i := range got.Max {
+		if math.Abs(got.Max[i]-want.Max[i]) > 1.0/127 || math.Abs(got.Min[i]-want.Min[i]) > 1.0/127 {
+			t.Fatalf("point %d = %v to %v, want %v to %v", i, got.Min[i], got.Max[i], want.Min[i], want.Max[i])
+		}
+	}
+
+	// A changed file needs a new scan.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(fileName, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Get(fileName); ok {
+		t.Errorf("Get(%s) found the waveform of a changed file", fileName)
+	}
+}
@@ -24,6 +24,20 @@ type delegateKeyMap struct {
 	changeMode key.Binding
 	next10s    key.Binding
 	priv10s    key.Binding
+	crossfade  key.Binding
+	fadeCurve  key.Binding
+	speedUp    key.Binding
+	speedDown  key.Binding
+	keepPitch  key.Binding
+	eqPreset   key.Binding
+	abLoop     key.Binding
+	sleep      key.Binding
+	visualizer key.Binding
+	trim       key.Binding
+	channels   key.Binding
+	balanceL   key.Binding
+	balanceR   key.Binding
+	crossfeed  key.Binding
 }
 
 // Additional short help entries. This satisfies the help.KeyMap interface and
@@ -38,6 +52,20 @@ func (d delegateKeyMap) ShortHelp() []key.Binding {
 		d.next10s,
 		d.priv10s,
 		d.changeMode,
+		d.crossfade,
+		d.fadeCurve,
+		d.speedDown,
+		d.speedUp,
+		d.keepPitch,
+		d.eqPreset,
+		d.abLoop,
+		d.sleep,
+		d.visualizer,
+		d.trim,
+		d.channels,
+		d.balanceL,
+		d.balanceR,
+		d.crossfeed,
 	}
 }
 
@@ -54,6 +82,20 @@ func (d delegateKeyMap) FullHelp() [][]key.Binding {
 			d.next10s,
 			d.priv10s,
 			d.changeMode,
+			d.crossfade,
+			d.fadeCurve,
+			d.speedDown,
+			d.speedUp,
+			d.keepPitch,
+			d.eqPreset,
+			d.abLoop,
+			d.sleep,
+			d.visualizer,
+			d.trim,
+			d.channels,
+			d.balanceL,
+			d.balanceR,
+			d.crossfeed,
 		},
 	}
 }
@@ -92,6 +134,62 @@ func newDelegateKeyMap() *delegateKeyMap {
 			key.WithKeys("z"),
 			key.WithHelp("z", "priv 10s"),
 		),
+		crossfade: key.NewBinding(
+			key.WithKeys("c"),
+			key.WithHelp("c", "crossfade"),
+		),
+		fadeCurve: key.NewBinding(
+			key.WithKeys("v"),
+			key.WithHelp("v", "fade curve"),
+		),
+		speedUp: key.NewBinding(
+			key.WithKeys("]"),
+			key.WithHelp("]", "faster"),
+		),
+		speedDown: key.NewBinding(
+			key.WithKeys("["),
+			key.WithHelp("[", "slower"),
+		),
+		keepPitch: key.NewBinding(
+			key.WithKeys("t"),
+			key.WithHelp("t", "keep pitch"),
+		),
+		eqPreset: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "eq preset"),
+		),
+		abLoop: key.NewBinding(
+			key.WithKeys("l"),
+			key.WithHelp("l", "a-b loop"),
+		),
+		sleep: key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "sleep timer"),
+		),
+		visualizer: key.NewBinding(
+			key.WithKeys("w"),
+			key.WithHelp("w", "visualizer"),
+		),
+		trim: key.NewBinding(
+			key.WithKeys("i"),
+			key.WithHelp("i", "trim silence"),
+		),
+		channels: key.NewBinding(
+			key.WithKeys("n"),
+			key.WithHelp("n", "channels"),
+		),
+		balanceL: key.NewBinding(
+			key.WithKeys(","),
+			key.WithHelp(",", "balance left"),
+		),
+		balanceR: key.NewBinding(
+			key.WithKeys("."),
+			key.WithHelp(".", "balance right"),
+		),
+		crossfeed: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "crossfeed"),
+		),
 	}
 }
 
@@ -107,6 +205,20 @@ func newItemDelegate(keys *delegateKeyMap) list.DefaultDelegate {
 		keys.next10s,
 		keys.priv10s,
 		keys.changeMode,
+		keys.crossfade,
+		keys.fadeCurve,
+		keys.speedDown,
+		keys.speedUp,
+		keys.keepPitch,
+		keys.eqPreset,
+		keys.abLoop,
+		keys.sleep,
+		keys.visualizer,
+		keys.trim,
+		keys.channels,
+		keys.balanceL,
+		keys.balanceR,
+		keys.crossfeed,
 	}
 
 	d.ShortHelpFunc = func() []key.Binding {
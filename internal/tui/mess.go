@@ -5,6 +5,7 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	playmanager "github.com/tommjj/music_player/internal/play_manager"
+	"github.com/tommjj/music_player/internal/player"
 )
 
 type songChangedMsg struct {
@@ -35,8 +36,30 @@ type playErrorMsg struct {
 	Error error
 }
 
-func tickEverySecond() tea.Cmd {
-	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+func newPlayErrorMsg(err error) tea.Cmd {
+	return func() tea.Msg {
+		return playErrorMsg{Error: err}
+	}
+}
+
+type playerEventMsg struct {
+	Event player.Event
+}
+
+// waitForEvent waits for the next event of the Player, the model waits again after every one.
+func waitForEvent(events <-chan player.Event) tea.Cmd {
+	return func() tea.Msg {
+		e, ok := <-events
+		if !ok {
+			return nil
+		}
+		return playerEventMsg{Event: e}
+	}
+}
+
+// tick redraws the view after d.
+func tick(d time.Duration) tea.Cmd {
+	return tea.Tick(d, func(t time.Time) tea.Msg {
 		return TickMsg(t)
 	})
 }
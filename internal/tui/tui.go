@@ -3,6 +3,9 @@ package tui
 
 import (
 	"fmt"
+	"math"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/list"
@@ -10,14 +13,50 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	playmanager "github.com/tommjj/music_player/internal/play_manager"
+	"github.com/tommjj/music_player/internal/player"
 )
 
 var docStyle = lipgloss.NewStyle().Margin(0, 1, 0, 1)
 
-var ()
+var errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#ff5f5f"))
+
+// crossfadeSteps are the crossfade lengths the crossfade key cycles through.
+var crossfadeSteps = []time.Duration{0, 2 * time.Second, 5 * time.Second, 8 * time.Second, 12 * time.Second}
+
+// sleepSteps are the sleep timers the sleep key cycles through before it
+// cancels the timer, 0 stops at the end of the current song.
+var sleepSteps = []time.Duration{15 * time.Minute, 30 * time.Minute, 60 * time.Minute, 0}
+
+// sleepFade is how long the sleep timer fades the volume out.
+const sleepFade = 30 * time.Second
+
+// channelModes are the channel modes the channel key cycles through.
+var channelModes = []player.ChannelMode{player.ChannelsStereo, player.ChannelsMono, player.ChannelsSwap, player.ChannelsKaraoke}
+
+// crossfeedPresets are the crossfeed presets the crossfeed key cycles through.
+var crossfeedPresets = []player.CrossfeedPreset{player.CrossfeedOff, player.CrossfeedLight, player.CrossfeedMedium, player.CrossfeedStrong}
+
+// balanceStep is how far the balance keys move the balance.
+const balanceStep = 0.1
 
 type Model struct {
 	playmanager *playmanager.PlayManager
+	events      <-chan player.Event // events of the Player, the view is redrawn on each
+
+	eqPresets *player.EQPresetStore
+	eqPreset  int // index of the applied preset in eqPresets.Presets()
+	sleep     int // index of the next sleep timer in sleepSteps
+
+	err error // the last playback error, shown until the next song starts
+
+	frameRate  int  // redraws a second while the visualizer is shown
+	visualizer bool // the spectrum and VU meter pane is shown
+
+	width, height int // the size of the terminal
+
+	waveforms    *player.WaveformCache // nil shows the progress bar
+	waveform     *player.Waveform      // the waveform of waveformPath, nil until it is loaded
+	waveformPath string
 
 	list list.Model
 
@@ -25,9 +64,29 @@ type Model struct {
 	progressPaused progress.Model
 }
 
+// SetFrameRate sets how many times a second the visualizer is redrawn.
+func (m *Model) SetFrameRate(fps int) {
+	m.frameRate = max(1, fps)
+}
+
+// SetWaveformCache shows the waveform of each song from cache in place of the
+// progress bar, a song is scanned into the cache when it starts.
+func (m *Model) SetWaveformCache(cache *player.WaveformCache) {
+	m.waveforms = cache
+}
+
 func (m Model) Init() tea.Cmd {
-	// Just return `nil`, which means "no I/O right now, please."
-	return tickEverySecond()
+	// The tick moves the progress bar and the visualizer, everything else is redrawn on events.
+	return tea.Batch(tick(m.frame()), waitForEvent(m.events))
+}
+
+// frame returns the time between two ticks, once a second is enough for the
+// progress bar alone.
+func (m Model) frame() time.Duration {
+	if !m.visualizer {
+		return time.Second
+	}
+	return time.Second / time.Duration(m.frameRate)
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -43,7 +102,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "enter":
 			if item, ok := m.list.SelectedItem().(Item); ok {
 				if err := m.playmanager.PlaySong(item.Song); err != nil {
-					return m, nil
+					return m, newPlayErrorMsg(err)
 				}
 				return m, newSongChangedMsg(item.Song)
 			}
@@ -74,6 +133,66 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				cmd = m.setPlayMode(playmanager.PlayModeNormal)
 			}
 			return m, cmd
+		case "c":
+			m.playmanager.SetCrossfade(nextCrossfade(m.playmanager.Crossfade()))
+		case "v":
+			if m.playmanager.CrossfadeCurve() == player.CrossfadeLinear {
+				m.playmanager.SetCrossfadeCurve(player.CrossfadeEqualPower)
+			} else {
+				m.playmanager.SetCrossfadeCurve(player.CrossfadeLinear)
+			}
+		case "]":
+			m.playmanager.Player.SpeedUp()
+		case "[":
+			m.playmanager.Player.SpeedDown()
+		case "t":
+			m.playmanager.Player.SetPreservePitch(!m.playmanager.Player.PreservePitch())
+		case "e":
+			presets := m.eqPresets.Presets()
+			m.eqPreset = (m.eqPreset + 1) % len(presets)
+			m.playmanager.Player.SetEQ(presets[m.eqPreset].Gains)
+		case "l":
+			// Mark A, then B, then clear the loop.
+			info := m.playmanager.Player.Info()
+			switch {
+			case info.Looping:
+				m.playmanager.Player.ClearLoop()
+			case info.HasLoopA:
+				m.playmanager.Player.SetLoopB()
+			default:
+				m.playmanager.Player.SetLoopA()
+			}
+		case "o":
+			if !m.playmanager.Sleep().Active {
+				m.sleep = 0
+			}
+			if m.sleep == len(sleepSteps) {
+				m.playmanager.CancelSleep()
+				m.sleep = 0
+			} else {
+				if d := sleepSteps[m.sleep]; d > 0 {
+					m.playmanager.SleepAfter(d, sleepFade)
+				} else {
+					m.playmanager.SleepAfterTracks(1, sleepFade)
+				}
+				m.sleep++
+			}
+		case "n":
+			m.playmanager.Player.SetChannelMode(nextChannelMode(m.playmanager.Player.ChannelMode()))
+		case "r":
+			current := slices.Index(crossfeedPresets, m.playmanager.Player.Crossfeed())
+			m.playmanager.Player.SetCrossfeed(crossfeedPresets[(current+1)%len(crossfeedPresets)])
+		case ",":
+			m.moveBalance(-balanceStep)
+		case ".":
+			m.moveBalance(balanceStep)
+		case "i":
+			trim := m.playmanager.Player.SilenceTrim()
+			trim.Enabled = !trim.Enabled
+			m.playmanager.Player.SetSilenceTrim(trim)
+		case "w":
+			m.visualizer = !m.visualizer
+			m.resize()
 		case "?":
 			m.list.Help.ShowAll = true
 			m.list.SetShowHelp(!m.list.ShowHelp())
@@ -82,12 +201,30 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case songChangedMsg:
 	case tea.WindowSizeMsg:
-		h, v := docStyle.GetFrameSize()
-		m.list.SetSize(msg.Width-h, msg.Height-v-4)
-		m.progress.Width = msg.Width - h
-		m.progressPaused.Width = msg.Width - h
+		m.width, m.height = msg.Width, msg.Height
+		m.resize()
 	case TickMsg:
-		return m, tickEverySecond()
+		return m, tick(m.frame())
+	case playerEventMsg:
+		switch msg.Event.Type {
+		case player.EventStarted:
+			m.err = nil
+			if m.waveforms != nil && msg.Event.Filepath != m.waveformPath {
+				m.waveform, m.waveformPath = nil, msg.Event.Filepath
+				return m, tea.Batch(waitForEvent(m.events), loadWaveform(m.waveforms, msg.Event.Filepath))
+			}
+		case player.EventDecodeError:
+			return m, tea.Batch(waitForEvent(m.events), newPlayErrorMsg(msg.Event.Err))
+		}
+		return m, waitForEvent(m.events)
+	case playErrorMsg:
+		m.err = msg.Error
+		return m, nil
+	case waveformMsg:
+		if msg.Path == m.waveformPath {
+			m.waveform = msg.Waveform
+		}
+		return m, nil
 	}
 
 	var cmd tea.Cmd
@@ -95,6 +232,21 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// resize fits the list and the progress bar into the terminal, leaving room for the visualizer.
+func (m *Model) resize() {
+	h, v := docStyle.GetFrameSize()
+	listHeight := m.height - v - 4
+	if m.visualizer {
+		listHeight -= visualizerHeight + 1
+	}
+	if m.waveforms != nil {
+		listHeight -= waveformRows - 1
+	}
+	m.list.SetSize(m.width-h, max(0, listHeight))
+	m.progress.Width = m.width - h
+	m.progressPaused.Width = m.width - h
+}
+
 func (m Model) setPlayMode(playMode string) tea.Cmd {
 	m.playmanager.SetPlayMode(playMode)
 
@@ -107,6 +259,29 @@ func (m Model) setPlayMode(playMode string) tea.Cmd {
 	return m.list.SetItems(items)
 }
 
+// nextChannelMode returns the channel mode after current.
+func nextChannelMode(current player.ChannelMode) player.ChannelMode {
+	i := slices.Index(channelModes, current)
+	return channelModes[(i+1)%len(channelModes)]
+}
+
+// moveBalance moves the balance by d, to the left for a negative d.
+func (m Model) moveBalance(d float64) {
+	// Rounded to the step, so the balance comes back to exactly 0.
+	balance := math.Round((m.playmanager.Player.Balance()+d)/balanceStep) * balanceStep
+	m.playmanager.Player.SetBalance(max(-1, min(balance, 1)))
+}
+
+// nextCrossfade returns the crossfade step after current.
+func nextCrossfade(current time.Duration) time.Duration {
+	for _, d := range crossfadeSteps {
+		if d > current {
+			return d
+		}
+	}
+	return crossfadeSteps[0]
+}
+
 func (m Model) View() string {
 	info := m.playmanager.Player.Info()
 	title := "no song play"
@@ -115,28 +290,105 @@ func (m Model) View() string {
 		title = currentSong.Title
 	}
 
+	status := fmt.Sprintf("[%v] ", m.playmanager.PlayMode())
+	if crossfade := m.playmanager.Crossfade(); crossfade > 0 {
+		status += fmt.Sprintf("[crossfade %v %v] ", crossfade, m.playmanager.CrossfadeCurve())
+	}
+	if info.Speed != 1 {
+		if info.PreservePitch {
+			status += fmt.Sprintf("[%gx keep pitch] ", info.Speed)
+		} else {
+			status += fmt.Sprintf("[%gx] ", info.Speed)
+		}
+	}
+	if presets := m.eqPresets.Presets(); m.eqPreset > 0 && m.eqPreset < len(presets) {
+		status += fmt.Sprintf("[eq %v] ", presets[m.eqPreset].Name)
+	}
+	if info.Limiting {
+		status += "[limit] "
+	}
+	if info.ChannelMode != player.ChannelsStereo {
+		status += fmt.Sprintf("[%v] ", info.ChannelMode)
+	}
+	if info.Crossfeed != player.CrossfeedOff {
+		status += fmt.Sprintf("[crossfeed %v] ", info.Crossfeed)
+	}
+	if info.Balance < 0 {
+		status += fmt.Sprintf("[balance L%.0f%%] ", -info.Balance*100)
+	} else if info.Balance > 0 {
+		status += fmt.Sprintf("[balance R%.0f%%] ", info.Balance*100)
+	}
+	if m.playmanager.Player.SilenceTrim().Enabled {
+		status += "[trim] "
+	}
+	if sleep := m.playmanager.Sleep(); sleep.Active {
+		if sleep.Known {
+			status += fmt.Sprintf("[sleep %v] ", sleep.Remaining.Round(time.Second))
+		} else {
+			status += fmt.Sprintf("[sleep after %d songs] ", sleep.Tracks)
+		}
+	}
+	if info.Looping {
+		status += fmt.Sprintf("[A-B %v-%v] ", info.LoopA.Round(time.Second), info.LoopB.Round(time.Second))
+	} else if info.HasLoopA {
+		status += fmt.Sprintf("[A %v-] ", info.LoopA.Round(time.Second))
+	}
+	status += title
+	if m.err != nil {
+		status += "\n" + errorStyle.Render(m.err.Error())
+	}
+
 	var progress string
-	if info.Paused {
+	switch {
+	case m.waveform != nil && info.Filepath == m.waveformPath:
+		progress = waveformBar(m.waveform, info, m.progress.Width)
+	case info.Paused:
 		progress = m.progressPaused.ViewAs(float64(info.Current) / float64(info.Length))
-	} else {
+	default:
 		progress = m.progress.ViewAs(float64(info.Current) / float64(info.Length))
 	}
+	if m.waveforms != nil && lipgloss.Height(progress) < waveformRows {
+		// Keep the room of the waveform while it loads.
+		progress = strings.Repeat("\n", waveformRows-1) + progress
+	}
+
+	panes := []string{
+		"",
+		status,
+		"",
+		progress,
+		loopMarkers(info, m.progress.Width),
+	}
+	if m.visualizer {
+		panes = append(panes, visualizer(m.playmanager.Player, m.progress.Width))
+	}
 
 	return lipgloss.JoinVertical(lipgloss.Left,
 		docStyle.Render(m.list.View()),
-		docStyle.Render(
-			lipgloss.JoinVertical(
-				lipgloss.Left,
-				"",
-				fmt.Sprintf("[%v] %v", m.playmanager.PlayMode(), title),
-				"",
-				progress,
-			),
-		),
+		docStyle.Render(lipgloss.JoinVertical(lipgloss.Left, panes...)),
 	)
 }
 
-func NewModel(pm *playmanager.PlayManager) Model {
+// loopMarkers returns a line that puts A and B under the progress bar where the loop points are.
+func loopMarkers(info *player.Info, width int) string {
+	if !info.HasLoopA || info.Length <= 0 || width <= 0 {
+		return ""
+	}
+
+	line := []rune(strings.Repeat(" ", width))
+	mark := func(pos time.Duration, r rune) {
+		col := int(float64(pos) / float64(info.Length) * float64(width-1))
+		line[max(0, min(col, width-1))] = r
+	}
+	mark(info.LoopA, 'A')
+	if info.Looping {
+		mark(info.LoopB, 'B')
+	}
+	return string(line)
+}
+
+// NewModel creates the TUI for pm, the EQ preset key cycles through the presets of eqPresets.
+func NewModel(pm *playmanager.PlayManager, eqPresets *player.EQPresetStore) Model {
 	playlist := pm.PlayList()
 
 	items := make([]list.Item, len(playlist))
@@ -149,14 +401,24 @@ func NewModel(pm *playmanager.PlayManager) Model {
 	list.SetShowStatusBar(false)
 	list.SetShowTitle(false)
 	list.SetShowHelp(false)
+	// "l" marks the A-B loop.
+	list.KeyMap.NextPage.SetKeys("right", "pgdown", "f", "d")
+	list.KeyMap.NextPage.SetHelp("→/pgdn", "next page")
 
 	prs := progress.New(progress.WithScaledGradient("#2f0bfdff", "#2f0bfdff"))
 	prs.ShowPercentage = false
 	prsP := progress.New(progress.WithScaledGradient("#b9b9b9ff", "#b9b9b9ff"))
 	prsP.ShowPercentage = false
 
+	// The TUI runs until the program exits, so the subscription is never cancelled.
+	events, _ := pm.Player.Subscribe()
+
 	return Model{
 		playmanager:    pm,
+		events:         events,
+		eqPresets:      eqPresets,
+		frameRate:      DefaultFrameRate,
+		visualizer:     true,
 		list:           list,
 		progress:       prs,
 		progressPaused: prsP,
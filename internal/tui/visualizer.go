@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tommjj/music_player/internal/player"
+)
+
+const (
+	// DefaultFrameRate is how many times a second the visualizer is redrawn.
+	DefaultFrameRate = 20
+
+	spectrumRows  = 6    // the height of the spectrum bars
+	spectrumFloor = -70. // dBFS at the bottom of a bar
+	spectrumLow   = 40.  // Hz of the first bar
+	spectrumHigh  = 16e3 // Hz of the end of the last bar
+	meterFloor    = -60. // dBFS at the left of a VU meter
+
+	// visualizerHeight is the number of lines of the visualizer pane.
+	visualizerHeight = spectrumRows + 2
+)
+
+var visualizerStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#2f0bfd"))
+
+// barBlocks fill a line of a bar from the bottom in eighths.
+var barBlocks = []rune(" ▁▂▃▄▅▆▇█")
+
+// visualizer renders the spectrum of p as bars over a VU meter per channel.
+func visualizer(p *player.Player, width int) string {
+	if width <= 0 {
+		return ""
+	}
+
+	lines := spectrumBars(p.Spectrum(), width)
+	levels := p.Levels()
+	for c, name := range []string{"L", "R"} {
+		lines = append(lines, vuMeter(name, levels.RMS[c], levels.Peak[c], width))
+	}
+	return visualizerStyle.Render(strings.Join(lines, "\n"))
+}
+
+// spectrumBars renders s as bars 2 columns wide, with a column between them.
+func spectrumBars(s player.Spectrum, width int) []string {
+	bands := s.Bands(max(1, (width+1)/3), spectrumLow, spectrumHigh)
+
+	lines := make([]string, spectrumRows)
+	for row := range lines {
+		var line strings.Builder
+		for i, db := range bands {
+			level := scale(db, spectrumFloor) * spectrumRows
+			// The part of the bar in this row, the top row is 0.
+			fill := max(0, min(level-float64(spectrumRows-1-row), 1))
+			block := barBlocks[int(fill*float64(len(barBlocks)-1))]
+			if i > 0 {
+				line.WriteRune(' ')
+			}
+			line.WriteRune(block)
+			line.WriteRune(block)
+		}
+		lines[row] = line.String()
+	}
+	return lines
+}
+
+// vuMeter renders the RMS of a channel as a bar with the peak marked on it.
+func vuMeter(name string, rms, peak float64, width int) string {
+	peakDB := math.Inf(-1)
+	if peak > 0 {
+		peakDB = 20 * math.Log10(peak)
+	}
+	label := fmt.Sprintf(" %6.1f dB", max(peakDB, meterFloor))
+
+	size := width - len(name) - 1 - len(label)
+	if size <= 0 {
+		return name
+	}
+	bar := []rune(strings.Repeat("─", size))
+	filled := int(scale(20*math.Log10(rms), meterFloor) * float64(size))
+	for i := range bar[:filled] {
+		bar[i] = '█'
+	}
+	if peak > 0 {
+		bar[min(int(scale(peakDB, meterFloor)*float64(size)), size-1)] = '│'
+	}
+	return name + " " + string(bar) + label
+}
+
+// scale maps db from floor to 0 dBFS onto 0 to 1.
+func scale(db, floor float64) float64 {
+	if math.IsNaN(db) {
+		return 0
+	}
+	return max(0, min((db-floor)/-floor, 1))
+}
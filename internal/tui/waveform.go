@@ -0,0 +1,80 @@
+package tui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tommjj/music_player/internal/player"
+)
+
+// waveformRows is the height of the waveform that replaces the progress bar.
+const waveformRows = 3
+
+var (
+	waveformPlayed       = lipgloss.NewStyle().Foreground(lipgloss.Color("#2f0bfd"))
+	waveformPlayedPaused = lipgloss.NewStyle().Foreground(lipgloss.Color("#b9b9b9"))
+	waveformAhead        = lipgloss.NewStyle().Foreground(lipgloss.Color("#585858"))
+	waveformPosition     = lipgloss.NewStyle().Foreground(lipgloss.Color("#ffaf00"))
+)
+
+type waveformMsg struct {
+	Path     string
+	Waveform *player.Waveform
+}
+
+// loadWaveform reads the waveform of path from cache, or scans it, off the UI goroutine.
+// A track that can not be scanned keeps the progress bar.
+func loadWaveform(cache *player.WaveformCache, path string) tea.Cmd {
+	return func() tea.Msg {
+		w, err := cache.Load(path)
+		if err != nil {
+			return nil
+		}
+		return waveformMsg{Path: path, Waveform: w}
+	}
+}
+
+// waveformBar renders w as bars, the part already played in the color of the
+// progress bar, with the current position marked.
+func waveformBar(w *player.Waveform, info *player.Info, width int) string {
+	if width <= 0 {
+		return ""
+	}
+
+	// The waveform covers the whole file, the length of a track can end
+	// before it when its silence is trimmed.
+	position := -1
+	if w.Duration > 0 {
+		position = min(int(float64(info.Current)/float64(w.Duration)*float64(width)), width-1)
+	}
+	played := waveformPlayed
+	if info.Paused {
+		played = waveformPlayedPaused
+	}
+
+	peaks := w.Peaks(width)
+	lines := make([]string, waveformRows)
+	for row := range lines {
+		var line strings.Builder
+		for i, peak := range peaks {
+			// The part of the bar in this row, the top row is 0.
+			fill := max(0, min(peak*waveformRows-float64(waveformRows-1-row), 1))
+			block := int(fill * float64(len(barBlocks)-1))
+			if row == waveformRows-1 {
+				block = max(block, 1) // silence still shows as a line
+			}
+
+			style := waveformAhead
+			switch {
+			case i == position:
+				style = waveformPosition
+			case i < position:
+				style = played
+			}
+			line.WriteString(style.Render(string(barBlocks[block])))
+		}
+		lines[row] = line.String()
+	}
+	return strings.Join(lines, "\n")
+}